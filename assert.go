@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/report"
+)
+
+//Nagios plugin exit codes
+const (
+	exitOK       = 0
+	exitWarning  = 1
+	exitCritical = 2
+	exitUnknown  = 3
+)
+
+var exitStatus = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+//exitRank orders the exit codes as the nagios plugins do, a failure outranks
+//a metric not measured
+var exitRank = []int{exitOK: 0, exitUnknown: 1, exitWarning: 2, exitCritical: 3}
+
+const (
+	successMetric = "success"
+	latencyMetric = "latency"
+)
+
+//phaseAliases are the short names of the phases in assertions
+var phaseAliases = map[string]string{
+	"dns":       report.DNSLookupField,
+	"proxy":     report.ProxyConnectionField,
+	"tunnel":    report.ProxyTunnelField,
+	"tcp":       report.TCPConnectionField,
+	"unix":      report.UnixConnectionField,
+	"tls":       report.TLSHandshakeField,
+	"websocket": report.WebSocketField,
+	"connect":   report.MQTTConnectionField,
+	"subscribe": "MQTT Subscribe",
+	"publish":   "MQTT Publish",
+	"message":   "MQTT Message Received",
+	"ping":      "MQTT PingPong",
+	"total":     report.TotalField,
+}
+
+var assertOps = []string{"<=", ">=", "<", ">"} //longer first
+
+//assertion is like connect<200ms, p99(publish)<50ms or success>=99%
+type assertion struct {
+	expr   string
+	fn     string //p50, min, max or mean, empty to hold in every round
+	metric string
+	op     string
+	limit  float64 //nanoseconds or percent
+}
+
+func parseAssertion(expr string) (*assertion, error) {
+	a := &assertion{expr: expr}
+	s := strings.Replace(expr, " ", "", -1)
+	var value string
+	for _, op := range assertOps {
+		if i := strings.Index(s, op); i > 0 {
+			a.op, a.metric, value = op, strings.ToLower(s[:i]), s[i+len(op):]
+			break
+		}
+	}
+	if a.op == "" {
+		return nil, errors.New("assertion " + expr + " should be like metric<value")
+	}
+
+	if i := strings.Index(a.metric, "("); i > 0 && strings.HasSuffix(a.metric, ")") {
+		a.fn, a.metric = a.metric[:i], a.metric[i+1:len(a.metric)-1]
+		switch {
+		case a.fn == "min", a.fn == "max", a.fn == "mean":
+		case strings.HasPrefix(a.fn, "p"):
+			if _, err := strconv.ParseFloat(a.fn[1:], 64); err != nil {
+				return nil, errors.New("unknown function " + a.fn + " of assertion " + expr)
+			}
+		default:
+			return nil, errors.New("unknown function " + a.fn + " of assertion " + expr)
+		}
+	}
+
+	if a.metric == successMetric {
+		if a.fn != "" {
+			return nil, errors.New("success of assertion " + expr + " takes no function")
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return nil, errors.New("success of assertion " + expr + " should be a percent like 99%")
+		}
+		a.limit = v
+		return a, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, errors.New("value of assertion " + expr + " should be a duration like 200ms")
+	}
+	a.limit = float64(d)
+	return a, nil
+}
+
+//phase is the name of the phase the metric refers to
+func (a *assertion) phase() string {
+	if name, found := phaseAliases[a.metric]; found {
+		return name
+	}
+	return a.metric
+}
+
+//value of the metric in rounds, false if it is not measured
+func (a *assertion) value(r *rounds) (float64, bool) {
+	if a.metric == successMetric {
+		total := r.ok + r.failed
+		if total == 0 {
+			return 0, false
+		}
+		return float64(r.ok) * 100 / float64(total), true
+	}
+
+	var m *series
+	var found bool
+	if a.metric == latencyMetric {
+		m, found = r.latency, r.latency.h.Count() > 0
+	} else {
+		m, found = r.phase(a.phase())
+	}
+	if !found {
+		return 0, false
+	}
+	switch a.fn {
+	case "min":
+		return float64(m.min), true
+	case "max":
+		return float64(m.max), true
+	case "mean":
+		return float64(m.h.Mean()), true
+	case "":
+		//the worst round decides
+		if a.op == "<" || a.op == "<=" {
+			return float64(m.max), true
+		}
+		return float64(m.min), true
+	}
+	p, _ := strconv.ParseFloat(a.fn[1:], 64)
+	return float64(m.h.Percentile(p)), true
+}
+
+func (a *assertion) holds(v float64) bool {
+	switch a.op {
+	case "<":
+		return v < a.limit
+	case "<=":
+		return v <= a.limit
+	case ">":
+		return v > a.limit
+	}
+	return v >= a.limit
+}
+
+//format v in the unit of the metric
+func (a *assertion) format(v float64) string {
+	if a.metric == successMetric {
+		return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+	}
+	return time.Duration(v).String()
+}
+
+//perfdata is the label=value;warn;crit of the metric, durations are in ms
+func (a *assertion) perfdata(v float64, known bool, warn bool) string {
+	value, limit, unit := v, a.limit, "%"
+	if a.metric != successMetric {
+		value, limit, unit = v/float64(time.Millisecond), a.limit/float64(time.Millisecond), "ms"
+	}
+	//nagios ranges alert outside of them and inside of them if prefixed by @,
+	//both ends are inclusive so < and > alert inside of the range up to the limit
+	threshold := strconv.FormatFloat(limit, 'f', -1, 64)
+	switch a.op {
+	case "<":
+		threshold = "@" + threshold + ":"
+	case ">":
+		threshold = "@~:" + threshold
+	case ">=":
+		threshold += ":"
+	}
+	label := a.metric
+	if a.fn != "" {
+		label = a.fn + "(" + a.metric + ")"
+	}
+	s := "'" + label + "'="
+	if known {
+		s += strconv.FormatFloat(value, 'f', 3, 64) + unit
+	} else {
+		s += "U"
+	}
+	if warn {
+		return s + ";" + threshold + ";"
+	}
+	return s + ";;" + threshold
+}
+
+//assertFlag collects the repeated assertions of -assert or -warn
+type assertFlag struct {
+	assertions *[]*assertion
+}
+
+func (f assertFlag) String() string {
+	if f.assertions == nil {
+		return ""
+	}
+	var s []string
+	for _, a := range *f.assertions {
+		s = append(s, a.expr)
+	}
+	return strings.Join(s, ",")
+}
+
+func (f assertFlag) Set(s string) error {
+	a, err := parseAssertion(s)
+	if err != nil {
+		return err
+	}
+	*f.assertions = append(*f.assertions, a)
+	return nil
+}
+
+//series records the values of a metric, min and max are exact while the
+//histogram rounds them to its buckets
+type series struct {
+	h        *bench.Histogram
+	min, max time.Duration
+}
+
+func newSeries() *series {
+	return &series{h: bench.NewHistogram()}
+}
+
+func (m *series) record(d time.Duration) {
+	if m.h.Count() == 0 || d < m.min {
+		m.min = d
+	}
+	if m.h.Count() == 0 || d > m.max {
+		m.max = d
+	}
+	m.h.Record(d)
+}
+
+//rounds aggregates the stats of the rounds to check the assertions
+type rounds struct {
+	ok, failed int
+	latency    *series
+	phases     map[string]*series
+	save       io.Writer //every round is written as a line of json if it is not nil
+}
+
+func newRounds() *rounds {
+	return &rounds{latency: newSeries(), phases: make(map[string]*series)}
+}
+
+func (r *rounds) phase(name string) (*series, bool) {
+	for n, m := range r.phases {
+		if strings.EqualFold(n, name) {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+func (r *rounds) record(name string, d time.Duration) {
+	m, found := r.phases[name]
+	if !found {
+		m = newSeries()
+		r.phases[name] = m
+	}
+	m.record(d)
+}
+
+//add a successful round, latency is measured from its intended start
+func (r *rounds) add(stat *report.Stat, latency time.Duration) error {
+	r.ok++
+	r.latency.record(latency)
+	for _, field := range stat.Fields {
+		r.record(field.Name, field.Cost)
+	}
+	r.record(report.TotalField, stat.Total())
+	if r.save != nil {
+		return report.JSON(r.save, stat)
+	}
+	return nil
+}
+
+func (r *rounds) fail() {
+	r.failed++
+}
+
+//checkAssertions prints the status line with perfdata of the nagios plugins
+//and returns the exit code, critical if any of crits fails and warning if
+//any of warns fails. A metric not measured is critical if rounds failed, as
+//an unreachable broker is, and unknown otherwise
+func checkAssertions(out io.Writer, crits, warns []*assertion, r *rounds) int {
+	code := exitOK
+	var failed, perfdata []string
+	check := func(a *assertion, warn bool) {
+		v, known := a.value(r)
+		perfdata = append(perfdata, a.perfdata(v, known, warn))
+		status := exitCritical
+		if warn {
+			status = exitWarning
+		}
+		switch {
+		case !known && r.failed > 0 && !warn:
+			failed = append(failed, a.expr+" (not measured)")
+		case !known:
+			status = exitUnknown
+			failed = append(failed, a.expr+" (not measured)")
+		case a.holds(v):
+			return
+		default:
+			failed = append(failed, a.expr+" ("+a.format(v)+")")
+		}
+		if exitRank[status] > exitRank[code] {
+			code = status
+		}
+	}
+	for _, a := range crits {
+		check(a, false)
+	}
+	for _, a := range warns {
+		check(a, true)
+	}
+	if r.ok == 0 && r.failed > 0 {
+		code = exitCritical
+		failed = append(failed, "all of the rounds failed")
+	}
+
+	summary := strconv.Itoa(len(crits)+len(warns)) + " assertions passed"
+	if len(failed) > 0 {
+		summary = strings.Join(failed, ", ")
+	}
+	fmt.Fprintf(out, "MQTTSTAT %v - %v, %v/%v rounds succeeded | %v\n", exitStatus[code], summary,
+		r.ok, r.ok+r.failed, strings.Join(perfdata, " "))
+	return code
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+//authFlags are the flags to generate the credentials of every round
+type authFlags struct {
+	kind      string
+	ttl       time.Duration
+	keyFile   string
+	audience  string
+	sharedKey string
+	resource  string
+	policy    string
+	region    string
+}
+
+func (a *authFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.kind, "auth", "", "generate the credentials of every round by jwt, sas or sigv4, or authenticate by MQTT 5 AUTH of scram-sha-1 or scram-sha-256 with the username and password")
+	fs.DurationVar(&a.ttl, "auth.ttl", time.Hour, "time to live of the jwt or sas token")
+	fs.StringVar(&a.keyFile, "auth.keyfile", "", "PEM file of the RSA or EC private key to sign the jwt")
+	fs.StringVar(&a.audience, "auth.audience", "", "audience of the jwt, like the project id of Google Cloud IoT")
+	fs.StringVar(&a.sharedKey, "auth.sharedkey", "", "base64 shared access key to sign the sas token, prefer "+envName("auth.sharedkey")+" to keep it out of the process list")
+	fs.StringVar(&a.resource, "auth.resource", "", "resource uri of the sas token, {host}/devices/{clientid} by default")
+	fs.StringVar(&a.policy, "auth.policy", "", "shared access policy name of the sas token")
+	fs.StringVar(&a.region, "auth.region", os.Getenv("AWS_REGION"), "aws region of sigv4, the keys are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN")
+}
+
+//authenticator returns nil if -auth is not a method of MQTT 5 enhanced authentication
+func (a *authFlags) authenticator() func() mqtt.Authenticator {
+	switch a.kind {
+	case "scram-sha-1":
+		return mqtt.NewSCRAMSHA1
+	case "scram-sha-256":
+		return mqtt.NewSCRAMSHA256
+	}
+	return nil
+}
+
+//provider returns nil if -auth is not set or it is a method of MQTT 5 enhanced authentication
+func (a *authFlags) provider(server string) (mqtt.CredentialProvider, error) {
+	switch a.kind {
+	case "", "scram-sha-1", "scram-sha-256":
+		return nil, nil
+	case "jwt":
+		if a.keyFile == "" {
+			return nil, errors.New("-auth.keyfile is required by jwt")
+		}
+		data, err := ioutil.ReadFile(a.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := mqtt.ParsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return &mqtt.JWT{Key: key, Audience: a.audience, TTL: a.ttl}, nil
+	case "sas":
+		if a.sharedKey == "" {
+			return nil, errors.New("-auth.sharedkey is required by sas")
+		}
+		return &mqtt.SAS{Resource: a.resource, Key: a.sharedKey, PolicyName: a.policy, TTL: a.ttl}, nil
+	case "sigv4":
+		s := &mqtt.SigV4{
+			Region:       a.region,
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if s.Region == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("-auth.region, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required by sigv4")
+		}
+		if !strings.HasPrefix(server, mqtt.WSSScheme) {
+			return nil, errors.New("sigv4 presigns a websocket url, -server should be like wss://host/mqtt")
+		}
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, errors.New("invalid server " + server)
+		}
+		//the path of the server is presigned, /mqtt if it is empty
+		s.Path = u.Path
+		return s, nil
+	}
+	return nil, errors.New("unknown auth " + a.kind + ", should be jwt, sas, sigv4, scram-sha-1 or scram-sha-256")
+}
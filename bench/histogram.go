@@ -0,0 +1,67 @@
+package bench
+
+import (
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	minValue = int64(time.Microsecond)
+	maxValue = int64(10 * time.Minute)
+	sigFigs  = 3
+)
+
+//Histogram records durations with a fixed relative precision
+type Histogram struct {
+	h *hdrhistogram.Histogram
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{h: hdrhistogram.New(minValue/int64(time.Microsecond), maxValue/int64(time.Microsecond), sigFigs)}
+}
+
+//Record records d in microseconds, values out of range are clamped
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d / time.Microsecond)
+	if v < 1 {
+		v = 1
+	}
+	if max := maxValue / int64(time.Microsecond); v > max {
+		v = max
+	}
+	h.h.RecordValue(v)
+}
+
+func (h *Histogram) Count() int64 {
+	return h.h.TotalCount()
+}
+
+//Percentile returns the value at p, which ranges in (0, 100]
+func (h *Histogram) Percentile(p float64) time.Duration {
+	return time.Duration(h.h.ValueAtQuantile(p)) * time.Microsecond
+}
+
+func (h *Histogram) Min() time.Duration {
+	return time.Duration(h.h.Min()) * time.Microsecond
+}
+
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.h.Max()) * time.Microsecond
+}
+
+func (h *Histogram) Mean() time.Duration {
+	return time.Duration(h.h.Mean() * float64(time.Microsecond))
+}
+
+func (h *Histogram) StdDev() time.Duration {
+	return time.Duration(h.h.StdDev() * float64(time.Microsecond))
+}
+
+func (h *Histogram) Merge(from *Histogram) {
+	h.h.Merge(from.h)
+}
+
+func (h *Histogram) Reset() {
+	h.h.Reset()
+}
@@ -0,0 +1,65 @@
+package bench
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func durations(vs ...int) []time.Duration {
+	ds := make([]time.Duration, len(vs))
+	for i, v := range vs {
+		ds[i] = time.Duration(v)
+	}
+	return ds
+}
+
+func TestMannWhitney(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []time.Duration
+		u, p float64
+	}{
+		//U = 0, z = 3.7418 by the normal approximation with continuity correction
+		{"separated", durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), durations(11, 12, 13, 14, 15, 16, 17, 18, 19, 20), 0, 0.00018267179110955},
+		//ties of 2, 3 and 4 reduce the variance from 30 to 29.4545
+		{"ties", durations(1, 2, 2, 3, 5), durations(2, 3, 4, 4, 6, 7), 6.5, 0.13862587987892772},
+		{"same", durations(1, 2, 3), durations(1, 2, 3), 4.5, 1},
+		{"empty", nil, durations(1), 0, 1},
+	}
+	for _, c := range cases {
+		u, p := MannWhitney(c.a, c.b)
+		if u != c.u || math.Abs(p-c.p) > 1e-12 {
+			t.Errorf("%v: got U=%v p=%v, want U=%v p=%v", c.name, u, p, c.u, c.p)
+		}
+	}
+}
+
+func TestMannWhitneySymmetric(t *testing.T) {
+	a, b := durations(5, 9, 12, 14, 20), durations(3, 4, 9, 10)
+	ua, pa := MannWhitney(a, b)
+	ub, pb := MannWhitney(b, a)
+	if ua+ub != float64(len(a)*len(b)) {
+		t.Errorf("U of a and b should add up to %v, got %v and %v", len(a)*len(b), ua, ub)
+	}
+	if pa != pb {
+		t.Errorf("p should not depend on the order, got %v and %v", pa, pb)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	cases := []struct {
+		ds   []time.Duration
+		want time.Duration
+	}{
+		{durations(3, 1, 2), 2},
+		{durations(40, 10, 30, 20), 25},
+		{durations(7), 7},
+		{nil, 0},
+	}
+	for _, c := range cases {
+		if got := Median(c.ds); got != c.want {
+			t.Errorf("Median(%v) = %v, want %v", c.ds, got, c.want)
+		}
+	}
+}
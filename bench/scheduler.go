@@ -0,0 +1,127 @@
+package bench
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//Arrival decides how the intended start times of operations are spaced
+type Arrival int
+
+const (
+	//Constant issues operations at a fixed interval of 1/rate
+	Constant Arrival = iota
+	//Poisson issues operations with exponentially distributed intervals of mean 1/rate
+	Poisson
+)
+
+func (a Arrival) String() string {
+	switch a {
+	case Constant:
+		return "constant"
+	case Poisson:
+		return "poisson"
+	}
+	return "unknown"
+}
+
+func ParseArrival(s string) (Arrival, error) {
+	switch s {
+	case "constant":
+		return Constant, nil
+	case "poisson":
+		return Poisson, nil
+	}
+	return Constant, errors.New("unknown arrival " + s + ", should be constant or poisson")
+}
+
+//Op is an operation issued by the scheduler, seq starts from 0
+type Op func(seq int) error
+
+//Result is the timing of an operation
+type Result struct {
+	Seq      int
+	Intended time.Time //the time the operation should have started
+	Start    time.Time //the time the operation actually started
+	End      time.Time
+	Err      error
+}
+
+//Latency is measured from the intended start, so the time an operation spent
+//waiting behind a stalled system is not omitted
+func (r *Result) Latency() time.Duration {
+	return r.End.Sub(r.Intended)
+}
+
+//ServiceTime is measured from the actual start, it is what a closed loop reports
+func (r *Result) ServiceTime() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+//Scheduler issues operations open-loop: every operation starts at its intended
+//time in its own goroutine no matter whether the former ones have finished
+type Scheduler struct {
+	Rate    float64 //operations per second, all of the operations are issued at once if it is not positive
+	Arrival Arrival
+
+	rand *rand.Rand
+}
+
+func NewScheduler(rate float64, arrival Arrival) *Scheduler {
+	return &Scheduler{Rate: rate, Arrival: arrival, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (s *Scheduler) interval() time.Duration {
+	if s.Rate <= 0 {
+		return 0
+	}
+	mean := float64(time.Second) / s.Rate
+	if s.Arrival == Poisson {
+		return time.Duration(s.rand.ExpFloat64() * mean)
+	}
+	return time.Duration(mean)
+}
+
+//Run issues count operations and calls report for each result as soon as it
+//finishes, it returns when all of the operations have finished or stop is
+//closed. report is called from a single goroutine
+func (s *Scheduler) Run(count int, op Op, report func(r *Result), stop <-chan struct{}) {
+	results := make(chan *Result, 64)
+	var wg sync.WaitGroup
+
+	go func() {
+		defer func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		intended := time.Now()
+		for seq := 0; seq < count; seq++ {
+			if d := time.Until(intended); d > 0 {
+				select {
+				case <-time.After(d):
+				case <-stop:
+					return
+				}
+			}
+
+			wg.Add(1)
+			go func(seq int, intended time.Time) {
+				defer wg.Done()
+				r := &Result{Seq: seq, Intended: intended, Start: time.Now()}
+				r.Err = op(seq)
+				r.End = time.Now()
+				results <- r
+			}(seq, intended)
+
+			//the schedule is fixed in advance, a late wakeup does not shift the following operations
+			intended = intended.Add(s.interval())
+		}
+	}()
+
+	for r := range results {
+		report(r)
+	}
+}
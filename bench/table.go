@@ -0,0 +1,26 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+//Percentiles are the columns printed by PrintHeader and PrintRow
+var Percentiles = []float64{50, 90, 99, 99.9}
+
+func PrintHeader(out io.Writer) {
+	fmt.Fprintf(out, "%-21v  %8v  %12v", "", "count", "min")
+	for _, p := range Percentiles {
+		fmt.Fprintf(out, "  %12v", "p"+strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	fmt.Fprintf(out, "  %12v\n", "max")
+}
+
+func PrintRow(out io.Writer, name string, h *Histogram) {
+	fmt.Fprintf(out, "%-21v  %8v  %12v", name, h.Count(), h.Min())
+	for _, p := range Percentiles {
+		fmt.Fprintf(out, "  %12v", h.Percentile(p))
+	}
+	fmt.Fprintf(out, "  %12v\n", h.Max())
+}
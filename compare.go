@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/mqtt"
+	"github.com/shafreeck/mqttstat/report"
+	"github.com/shafreeck/mqttstat/subcmd"
+)
+
+func init() {
+	subcmd.Register(&subcmd.Command{
+		Name:    "compare",
+		Help:    "compare a baseline saved by -save with a file or a new run: compare [options] baseline (file | subcommand [options])",
+		Flags:   func(fs *flag.FlagSet) { new(compareOptions).register(fs) },
+		Clients: compareCommand,
+	})
+}
+
+type compareOptions struct {
+	count int
+	delay time.Duration
+	alpha float64
+	save  string
+}
+
+func (o *compareOptions) register(fs *flag.FlagSet) {
+	fs.IntVar(&o.count, "count", 20, "count of rounds to run")
+	fs.DurationVar(&o.delay, "delay", 200*time.Millisecond, "time to delay before next round")
+	fs.Float64Var(&o.alpha, "alpha", 0.05, "p-value below which a difference is significant")
+	fs.StringVar(&o.save, "save", "", "save the rounds of the new run to a file")
+}
+
+//compareCommand compares the rounds of a baseline with the rounds of a file or
+//of the subcommand run now, phase by phase
+func compareCommand(cfg *mqtt.ClientConfig, address string, args []string) error {
+	var o compareOptions
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("compare needs a baseline and a file or a subcommand to run")
+	}
+
+	baseName, curName := fs.Arg(0), fs.Arg(1)
+	base, err := loadStats(baseName)
+	if err != nil {
+		return err
+	}
+
+	var cur []*report.Stat
+	failed := 0
+	if cmd, found := subcmd.Lookup(curName); found {
+		if cmd.Run == nil {
+			return errors.New("compare can not run " + cmd.Name)
+		}
+		curName = address
+		cur, failed, err = runStats(cfg, address, fs.Args()[1:], &o)
+	} else {
+		cur, err = loadStats(curName)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(color(GreyFmt, "Baseline"), ":", color(GreenFmt, baseName), "with", len(base), "rounds")
+	fmt.Print(color(GreyFmt, "Current"), " : ", color(GreenFmt, curName), " with ", len(cur), " rounds")
+	if failed > 0 {
+		fmt.Print(", ", color(RedFmt, strconv.Itoa(failed)+" failed"))
+	}
+	fmt.Println()
+	fmt.Println()
+	compareStats(os.Stdout, base, cur, o.alpha)
+	return nil
+}
+
+//loadStats reads the rounds saved by -save
+func loadStats(path string) ([]*report.Stat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stats, err := report.ReadJSON(f)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	if len(stats) == 0 {
+		return nil, errors.New(path + " has no rounds")
+	}
+	return stats, nil
+}
+
+//runStats runs the subcommand in args for the rounds of o, the failed rounds
+//are counted and left out
+func runStats(cfg *mqtt.ClientConfig, address string, args []string, o *compareOptions) ([]*report.Stat, int, error) {
+	rs := newRounds()
+	if o.save != "" {
+		f, err := os.Create(o.save)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer f.Close()
+		rs.save = f
+	}
+
+	var stats []*report.Stat
+	for i := 0; i < o.count; i++ {
+		c := mqtt.NewClient(cfg)
+		c.SetRound(i + 1)
+		var stat *report.Stat
+		err := runRound(c, address, args)
+		if err == nil {
+			stat, err = report.FromClient(c, address, phasesOf(args))
+		}
+		if err != nil {
+			rs.fail()
+			fmt.Fprintln(os.Stderr, color(RedFmt, err))
+		} else {
+			if err := rs.add(stat, stat.Total()); err != nil {
+				return nil, 0, err
+			}
+			stats = append(stats, stat)
+		}
+		if i < o.count-1 {
+			time.Sleep(o.delay)
+		}
+	}
+	if len(stats) == 0 {
+		return nil, rs.failed, errors.New("all of the rounds failed")
+	}
+	return stats, rs.failed, nil
+}
+
+//samples collects the costs of every phase and the total, names are in the
+//order the phases appear
+func samples(stats []*report.Stat, names []string, costs map[string][]time.Duration) []string {
+	for _, stat := range stats {
+		for _, field := range stat.Fields {
+			if !contains(names, field.Name) {
+				names = append(names, field.Name)
+			}
+			costs[field.Name] = append(costs[field.Name], field.Cost)
+		}
+		costs[report.TotalField] = append(costs[report.TotalField], stat.Total())
+	}
+	return names
+}
+
+//compareStats prints the median of every phase in base and cur, the delta and
+//the p-value of the Mann-Whitney U test, significant regressions are red
+func compareStats(out io.Writer, base, cur []*report.Stat, alpha float64) {
+	baseCosts := make(map[string][]time.Duration)
+	curCosts := make(map[string][]time.Duration)
+	names := samples(base, nil, baseCosts)
+	names = samples(cur, names, curCosts)
+	names = append(names, report.TotalField)
+
+	fmt.Fprintf(out, "%-21v  %12v  %12v  %12v  %8v  %8v\n", "Phase (p50)", "baseline", "current", "delta", "change", "p-value")
+	for _, name := range names {
+		b, c := baseCosts[name], curCosts[name]
+		if len(b) == 0 || len(c) == 0 {
+			fmt.Fprintf(out, "%-21v  %12v  %12v\n", name, median(b), median(c))
+			continue
+		}
+
+		_, p := bench.MannWhitney(b, c)
+		bm, cm := bench.Median(b), bench.Median(c)
+		delta := cm - bm
+		sign := ""
+		if delta > 0 {
+			sign = "+"
+		}
+		change := "-"
+		if bm > 0 {
+			change = fmt.Sprintf("%+.1f%%", float64(delta)*100/float64(bm))
+		}
+
+		diff := fmt.Sprintf("%12v  %8v", sign+delta.String(), change)
+		verdict := ""
+		switch {
+		case p < alpha && delta > 0:
+			diff, verdict = color(RedFmt, diff), "  "+color(RedFmt, "slower")
+		case p < alpha && delta < 0:
+			diff, verdict = color(GreenFmt, diff), "  "+color(GreenFmt, "faster")
+		}
+		fmt.Fprintf(out, "%-21v  %12v  %12v  %v  %8.3f%v\n", name, bm, cm, diff, p, verdict)
+	}
+}
+
+//median of ds, "-" if there is none
+func median(ds []time.Duration) string {
+	if len(ds) == 0 {
+		return "-"
+	}
+	return bench.Median(ds).String()
+}
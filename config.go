@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//envPrefix prefixes the environment variables overriding the flags
+const envPrefix = "MQTTSTAT_"
+
+//profile is a named set of global flags, the keys are the names of the flags
+//and "args" is the default subcommand with its options
+type profile map[string]interface{}
+
+type configFile struct {
+	Default  string             `yaml:"default"` //profile used if -profile is not set
+	Profiles map[string]profile `yaml:"profiles"`
+}
+
+//envName is the environment variable of a flag, like MQTTSTAT_TCP_NODELAY of tcp.nodelay
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+//defaultConfigPath is ~/.mqttstat.yaml, it is empty if the home is unknown
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mqttstat.yaml")
+}
+
+//applyEnv sets the flags not set on the command line from the environment,
+//it returns the names of the flags set by either of them
+func applyEnv(fs *flag.FlagSet) (map[string]bool, error) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		if v, found := os.LookupEnv(envName(f.Name)); found {
+			if err = fs.Set(f.Name, v); err != nil {
+				err = fmt.Errorf("invalid %v: %v", envName(f.Name), err)
+			}
+			set[f.Name] = true
+		}
+	})
+	return set, err
+}
+
+//applyProfile sets the flags not in set from the profile of the config file
+//at path, it returns the default subcommand args of the profile. The default
+//profile of the file is used if name is empty, nothing is applied if there
+//is neither the profile nor the file at the default path
+func applyProfile(fs *flag.FlagSet, set map[string]bool, path, name string) ([]string, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit && name == "" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var conf configFile
+	if err := yaml.UnmarshalStrict(data, &conf); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if name == "" {
+		name = conf.Default
+	}
+	if name == "" {
+		return nil, nil
+	}
+	p, found := conf.Profiles[name]
+	if !found {
+		return nil, errors.New("profile " + name + " is not found in " + path)
+	}
+
+	warnReadable(path, p)
+
+	var args []string
+	for key, value := range p {
+		values, isList := value.([]interface{})
+		if !isList {
+			values = []interface{}{value}
+		}
+		if key == "args" {
+			for _, v := range values {
+				args = append(args, fmt.Sprint(v))
+			}
+			continue
+		}
+		if fs.Lookup(key) == nil {
+			return nil, fmt.Errorf("unknown flag %v in profile %v", key, name)
+		}
+		if set[key] {
+			continue
+		}
+		//a list sets a repeatable flag for each of the values
+		for _, v := range values {
+			if err := fs.Set(key, fmt.Sprint(v)); err != nil {
+				return nil, fmt.Errorf("invalid %v in profile %v: %v", key, name, err)
+			}
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+var tlvTypes = map[string]byte{
+	"alpn":      mqtt.PP2TypeALPN,
+	"authority": mqtt.PP2TypeAuthority,
+	"crc32c":    mqtt.PP2TypeCRC32C,
+	"noop":      mqtt.PP2TypeNoop,
+	"unique_id": mqtt.PP2TypeUniqueID,
+}
+
+//tlvFlag collects the repeated type=value flags of PROXY protocol v2 TLVs, the
+//type is a name or a number and the value is hex if it is prefixed by 0x
+type tlvFlag struct {
+	tlvs *[]mqtt.TLV
+}
+
+func (f tlvFlag) String() string {
+	if f.tlvs == nil {
+		return ""
+	}
+	var s []string
+	for _, tlv := range *f.tlvs {
+		s = append(s, strconv.Itoa(int(tlv.Type))+"=0x"+hex.EncodeToString(tlv.Value))
+	}
+	return strings.Join(s, ",")
+}
+
+func (f tlvFlag) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return errors.New("tlv should be type=value")
+	}
+
+	typ, found := tlvTypes[strings.ToLower(kv[0])]
+	if !found {
+		n, err := strconv.ParseUint(kv[0], 0, 8)
+		if err != nil {
+			return errors.New("unknown tlv type " + kv[0])
+		}
+		typ = byte(n)
+	}
+
+	value := []byte(kv[1])
+	if strings.HasPrefix(kv[1], "0x") {
+		v, err := hex.DecodeString(kv[1][2:])
+		if err != nil {
+			return err
+		}
+		value = v
+	}
+	*f.tlvs = append(*f.tlvs, mqtt.TLV{Type: typ, Value: value})
+	return nil
+}
@@ -0,0 +1,56 @@
+package mqtt
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/url"
+)
+
+//Credentials are generated by a CredentialProvider for a connection, the
+//fields not empty override the ones of the config
+type Credentials struct {
+	Username string
+	Password string
+	URL      string //address to connect to instead, like a presigned websocket url
+}
+
+//CredentialProvider generates the credentials before every connection, so
+//the tokens are refreshed by rounds
+type CredentialProvider interface {
+	Credentials(host, port, clientID string) (*Credentials, error)
+}
+
+//stripURL drops the url from the errors of url.Parse, which may carry the
+//password of a proxy or the token of a presigned url
+func stripURL(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return errors.New("invalid url: " + e.Err.Error())
+	}
+	return err
+}
+
+//ParsePrivateKey parses a PEM encoded RSA or EC private key in PKCS #1, SEC 1
+//or PKCS #8 form
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data of private key is found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key can not sign")
+	}
+	return signer, nil
+}
@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+//ErrNoFreePort is returned if all of the ports of LocalPorts are in use
+var ErrNoFreePort = errors.New("no free local port in range")
+
+//PortRange is a range of local ports to bind, the ports are used in turn by
+//all of the clients sharing the config
+type PortRange struct {
+	Min, Max int
+	next     uint32
+}
+
+//ParsePortRange parses range like 20000-30000 or a single port
+func ParsePortRange(s string) (*PortRange, error) {
+	parts := strings.SplitN(s, "-", 2)
+	min, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	max := min
+	if len(parts) == 2 {
+		if max, err = strconv.Atoi(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	if min <= 0 || max > 65535 || max < min {
+		return nil, errors.New("invalid port range " + s)
+	}
+	return &PortRange{Min: min, Max: max}, nil
+}
+
+func (r *PortRange) size() int {
+	return r.Max - r.Min + 1
+}
+
+//Next port to bind
+func (r *PortRange) Next() int {
+	n := atomic.AddUint32(&r.next, 1) - 1
+	return r.Min + int(n%uint32(r.size()))
+}
+
+//dialContext connects to addr by tcp from the local address and interface of the config
+func (c *Client) dialContext(ctx context.Context, d net.Dialer, addr string) (net.Conn, error) {
+	if c.cfg.Interface != "" {
+		control, err := bindToDevice(c.cfg.Interface)
+		if err != nil {
+			return nil, err
+		}
+		d.Control = control
+	}
+
+	var ip net.IP
+	if c.cfg.LocalIP != "" {
+		if ip = net.ParseIP(c.cfg.LocalIP); ip == nil {
+			return nil, errors.New("invalid local ip " + c.cfg.LocalIP)
+		}
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	ports := c.cfg.LocalPorts
+	if ports == nil {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	//try the next port if the port is in use
+	for i := 0; i < ports.size(); i++ {
+		d.LocalAddr = &net.TCPAddr{IP: ip, Port: ports.Next()}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if errors.Is(err, syscall.EADDRINUSE) {
+			continue
+		}
+		return conn, err
+	}
+	return nil, fmt.Errorf("%w %v-%v", ErrNoFreePort, ports.Min, ports.Max)
+}
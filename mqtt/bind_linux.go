@@ -0,0 +1,20 @@
+package mqtt
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+//bindToDevice binds the socket to the network interface by SO_BINDTODEVICE
+func bindToDevice(iface string) (func(network, address string, c syscall.RawConn) error, error) {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		if err := c.Control(func(fd uintptr) {
+			serr = unix.BindToDevice(int(fd), iface)
+		}); err != nil {
+			return err
+		}
+		return serr
+	}, nil
+}
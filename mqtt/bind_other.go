@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package mqtt
+
+import (
+	"errors"
+	"syscall"
+)
+
+func bindToDevice(iface string) (func(network, address string, c syscall.RawConn) error, error) {
+	return nil, errors.New("binding to interface is supported on linux only")
+}
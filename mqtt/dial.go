@@ -0,0 +1,211 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+//DefaultAttemptDelay is the delay between connection attempts of Happy Eyeballs recommended by RFC 8305
+const DefaultAttemptDelay = 250 * time.Millisecond
+
+//DialAttempt is an attempt to connect to an address
+type DialAttempt struct {
+	Addr  string
+	Begin time.Time
+	End   time.Time
+	Err   error //context.Canceled if another attempt won
+	Won   bool
+}
+
+//Family of the address, IPv4 or IPv6
+func (a *DialAttempt) Family() string {
+	return family(a.Addr)
+}
+
+func family(ip string) string {
+	if p := net.ParseIP(ip); p != nil && p.To4() == nil {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+//resolve returns the ips of host to connect to
+func (c *Client) resolve(host string) ([]string, error) {
+	var ips []string
+	switch {
+	case c.ip != "":
+		ips = []string{c.ip}
+	case net.ParseIP(host) != nil:
+		ips = []string{host}
+	case c.cfg.Resolver != nil:
+		res, err := c.cfg.Resolver.Lookup(host, c.tracer)
+		c.dns = res
+		if err != nil {
+			return nil, err
+		}
+		ips = res.Addrs
+	default:
+		if c.tracer != nil {
+			c.tracer.AddPoint(TraceDNSLookup, time.Now())
+		}
+		addrs, err := net.LookupHost(host)
+		if err != nil {
+			return nil, err
+		}
+		ips = addrs
+	}
+	return FilterFamily(c.cfg.Network, host, ips)
+}
+
+//FilterFamily returns the ips of host in the family of network, tcp4 or tcp6,
+//or all of them for other networks. It fails if none of them is left
+func FilterFamily(network, host string, ips []string) ([]string, error) {
+	want := ""
+	switch network {
+	case "tcp4":
+		want = "IPv4"
+	case "tcp6":
+		want = "IPv6"
+	}
+	if want == "" {
+		return ips, nil
+	}
+
+	var filtered []string
+	for _, ip := range ips {
+		if family(ip) == want {
+			filtered = append(filtered, ip)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, errors.New("no " + want + " address found for " + host)
+	}
+	return filtered, nil
+}
+
+//interleave sorts ips by alternating the families, starting from IPv6 as RFC 8305
+func interleave(ips []string) []string {
+	var v4, v6 []string
+	for _, ip := range ips {
+		if family(ip) == "IPv6" {
+			v6 = append(v6, ip)
+		} else {
+			v4 = append(v4, ip)
+		}
+	}
+
+	sorted := make([]string, 0, len(ips))
+	for i := 0; i < len(v4) || i < len(v6); i++ {
+		if i < len(v6) {
+			sorted = append(sorted, v6[i])
+		}
+		if i < len(v4) {
+			sorted = append(sorted, v4[i])
+		}
+	}
+	return sorted
+}
+
+//dialTCP connects to the first of ips, or races all of them with Happy Eyeballs
+func (c *Client) dialTCP(d net.Dialer, ips []string, port string) (net.Conn, error) {
+	if c.tracer != nil {
+		c.tracer.AddPoint(TraceTCPDial, time.Now())
+	}
+
+	if !c.cfg.HappyEyeballs || len(ips) == 1 {
+		a := &DialAttempt{Addr: ips[0], Begin: time.Now()}
+		conn, err := c.dialContext(context.Background(), d, net.JoinHostPort(ips[0], port))
+		a.End, a.Err, a.Won = time.Now(), err, err == nil
+		c.attempts = []*DialAttempt{a}
+		return conn, err
+	}
+	return c.happyEyeballs(d, interleave(ips), port)
+}
+
+//happyEyeballs starts an attempt every AttemptDelay or as soon as the former
+//one failed, the first connected one wins and the others are canceled
+func (c *Client) happyEyeballs(d net.Dialer, ips []string, port string) (net.Conn, error) {
+	delay := c.cfg.AttemptDelay
+	if delay <= 0 {
+		delay = DefaultAttemptDelay
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		conn    net.Conn
+		attempt *DialAttempt
+	}
+	results := make(chan result, len(ips))
+	var attempts []*DialAttempt
+	start := func() {
+		a := &DialAttempt{Addr: ips[len(attempts)], Begin: time.Now()}
+		attempts = append(attempts, a)
+		go func() {
+			conn, err := c.dialContext(ctx, d, net.JoinHostPort(a.Addr, port))
+			a.End, a.Err = time.Now(), err
+			results <- result{conn: conn, attempt: a}
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var winner net.Conn
+	var err error
+	start()
+	for pending := 1; pending > 0; {
+		select {
+		case r := <-results:
+			pending--
+			if r.attempt.Err != nil {
+				if err == nil {
+					err = r.attempt.Err
+				}
+				if winner == nil && len(attempts) < len(ips) {
+					start()
+					pending++
+					timer.Reset(delay)
+				}
+				continue
+			}
+			if winner != nil {
+				r.conn.Close() //connected before it is canceled
+				continue
+			}
+			winner = r.conn
+			r.attempt.Won = true
+			cancel()
+		case <-timer.C:
+			if winner == nil && len(attempts) < len(ips) {
+				start()
+				pending++
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	c.attempts = attempts
+	if winner == nil {
+		return nil, err
+	}
+	return winner, nil
+}
+
+func (c *Client) dialUnix(d net.Dialer, path string) (net.Conn, error) {
+	if c.cfg.Proxy != "" {
+		return nil, errors.New("unix socket can not be connected through a proxy")
+	}
+	if c.tracer != nil {
+		c.tracer.AddPoint(TraceUnixDial, time.Now())
+	}
+	return d.Dial("unix", path)
+}
+
+//DialAttempts are the attempts to connect made by Dial
+func (c *Client) DialAttempts() []*DialAttempt {
+	return c.attempts
+}
@@ -0,0 +1,26 @@
+package mqtt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterFamily(t *testing.T) {
+	ips := []string{"192.0.2.1", "2001:db8::1", "192.0.2.2", "::ffff:192.0.2.3"}
+	for network, want := range map[string][]string{
+		"tcp":  ips,
+		"tcp4": {"192.0.2.1", "192.0.2.2", "::ffff:192.0.2.3"},
+		"tcp6": {"2001:db8::1"},
+	} {
+		got, err := FilterFamily(network, "example.com", ips)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", network, got, want)
+		}
+	}
+	if _, err := FilterFamily("tcp6", "example.com", []string{"192.0.2.1"}); err == nil {
+		t.Error("no IPv6 address should fail")
+	}
+}
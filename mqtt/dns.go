@@ -0,0 +1,333 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+//Resolver looks up the addresses of a host by querying A and AAAA one after
+//another, so that the cost of each query can be traced
+type Resolver struct {
+	Server  string //host:port of the dns server, the system resolver is used if both Server and DoHURL are empty
+	Network string //udp or tcp, udp falls back to tcp if the response is truncated
+	DoHURL  string //url of a DNS-over-HTTPS server, like https://1.1.1.1/dns-query
+	Timeout time.Duration
+}
+
+//DNSRecord is a record in the answers of a query
+type DNSRecord struct {
+	Name  string
+	Type  string
+	TTL   uint32 //always 0 with the system resolver
+	Value string
+}
+
+//DNSQuery is a query sent to the resolver
+type DNSQuery struct {
+	Type    string
+	Begin   time.Time
+	End     time.Time
+	Records []DNSRecord
+	Err     error
+}
+
+//DNSResult is the result of a lookup
+type DNSResult struct {
+	Resolver string
+	Queries  []*DNSQuery
+	CNAMEs   []string //the canonical names followed from the host
+	Addrs    []string
+}
+
+//Name of the resolver
+func (r *Resolver) Name() string {
+	switch {
+	case r.DoHURL != "":
+		return r.DoHURL
+	case r.Server != "":
+		network := r.Network
+		if network == "" {
+			network = "udp"
+		}
+		return network + "://" + r.Server
+	}
+	return "system"
+}
+
+//Lookup queries A and AAAA records of host, trace points of each query are
+//added to tracer if it is not nil
+func (r *Resolver) Lookup(host string, tracer Tracer) (*DNSResult, error) {
+	res := &DNSResult{Resolver: r.Name()}
+	for _, q := range []struct {
+		key string
+		typ dnsmessage.Type
+	}{{TraceDNSQueryA, dnsmessage.TypeA}, {TraceDNSQueryAAAA, dnsmessage.TypeAAAA}} {
+		query := &DNSQuery{Type: typeName(q.typ), Begin: time.Now()}
+		if tracer != nil {
+			tracer.AddPoint(q.key, query.Begin)
+		}
+		query.Records, query.Err = r.query(host, q.typ)
+		query.End = time.Now()
+		res.Queries = append(res.Queries, query)
+	}
+
+	var records []DNSRecord
+	for _, q := range res.Queries {
+		records = append(records, q.Records...)
+	}
+	for _, rr := range records {
+		if rr.Type == "A" || rr.Type == "AAAA" {
+			res.Addrs = append(res.Addrs, rr.Value)
+		}
+	}
+
+	//follow the cname chain from the host
+	seen := make(map[string]bool)
+	for name := canonicalName(host); !seen[name]; {
+		seen[name] = true
+		for _, rr := range records {
+			if rr.Type == "CNAME" && strings.EqualFold(rr.Name, name) {
+				res.CNAMEs = append(res.CNAMEs, rr.Value)
+				name = rr.Value
+				break
+			}
+		}
+	}
+
+	if len(res.Addrs) == 0 {
+		for _, q := range res.Queries {
+			if q.Err != nil {
+				return res, q.Err
+			}
+		}
+		return res, errors.New("no address found for " + host)
+	}
+	return res, nil
+}
+
+func canonicalName(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
+
+func typeName(t dnsmessage.Type) string {
+	switch t {
+	case dnsmessage.TypeA:
+		return "A"
+	case dnsmessage.TypeAAAA:
+		return "AAAA"
+	case dnsmessage.TypeCNAME:
+		return "CNAME"
+	}
+	return t.String()
+}
+
+func (r *Resolver) query(host string, t dnsmessage.Type) ([]DNSRecord, error) {
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	if r.Server == "" && r.DoHURL == "" {
+		network := "ip4"
+		if t == dnsmessage.TypeAAAA {
+			network = "ip6"
+		}
+		ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+		if err != nil {
+			//a host may have no address of the family
+			var dnsErr *net.DNSError
+			var addrErr *net.AddrError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound || errors.As(err, &addrErr) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		var records []DNSRecord
+		for _, ip := range ips {
+			records = append(records, DNSRecord{Name: host, Type: typeName(t), Value: ip.String()})
+		}
+		return records, nil
+	}
+
+	name, err := dnsmessage.NewName(canonicalName(host))
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: uint16(rand.Intn(1 << 16)), RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: t, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	req, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []byte
+	switch {
+	case r.DoHURL != "":
+		resp, err = r.exchangeHTTPS(ctx, req)
+	case r.Network == "tcp":
+		resp, err = r.exchangeTCP(ctx, req)
+	default:
+		resp, err = r.exchangeUDP(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseAnswers(resp)
+}
+
+func (r *Resolver) exchangeUDP(ctx context.Context, req []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", r.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, 65535)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		var p dnsmessage.Parser
+		h, err := p.Start(resp[:n])
+		if err != nil || h.ID != binary.BigEndian.Uint16(req) {
+			continue //not the response of this query
+		}
+		if h.Truncated {
+			return r.exchangeTCP(ctx, req)
+		}
+		return resp[:n], nil
+	}
+}
+
+func (r *Resolver) exchangeTCP(ctx context.Context, req []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := make([]byte, 2+len(req))
+	binary.BigEndian.PutUint16(msg, uint16(len(req)))
+	copy(msg[2:], req)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var l [2]byte
+	if _, err := io.ReadFull(conn, l[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Resolver) exchangeHTTPS(ctx context.Context, req []byte) ([]byte, error) {
+	hreq, err := http.NewRequest("POST", r.DoHURL, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	hreq = hreq.WithContext(ctx)
+	hreq.Header.Set("Content-Type", "application/dns-message")
+	hreq.Header.Set("Accept", "application/dns-message")
+
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("DNS-over-HTTPS server returned " + resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func parseAnswers(resp []byte) ([]DNSRecord, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return nil, err
+	}
+	if h.RCode != dnsmessage.RCodeSuccess && h.RCode != dnsmessage.RCodeNameError {
+		return nil, errors.New("DNS query failed: " + h.RCode.String())
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+
+	var records []DNSRecord
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rr := DNSRecord{Name: rh.Name.String(), Type: typeName(rh.Type), TTL: rh.TTL}
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			a, err := p.AResource()
+			if err != nil {
+				return nil, err
+			}
+			rr.Value = net.IP(a.A[:]).String()
+		case dnsmessage.TypeAAAA:
+			a, err := p.AAAAResource()
+			if err != nil {
+				return nil, err
+			}
+			rr.Value = net.IP(a.AAAA[:]).String()
+		case dnsmessage.TypeCNAME:
+			c, err := p.CNAMEResource()
+			if err != nil {
+				return nil, err
+			}
+			rr.Value = c.CNAME.String()
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		records = append(records, rr)
+	}
+	return records, nil
+}
@@ -0,0 +1,95 @@
+package mqtt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+//JWT signs a JSON web token as the password, like Google Cloud IoT Core.
+//RSA keys sign RS256 and EC keys ES256, ES384 or ES512 by the curve
+type JWT struct {
+	Key      crypto.Signer
+	Audience string
+	TTL      time.Duration
+	Claims   map[string]interface{} //extra claims
+
+	now func() time.Time //time.Now by default
+}
+
+func (j *JWT) algorithm() (string, crypto.Hash, error) {
+	switch key := j.Key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256", crypto.SHA256, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return "ES256", crypto.SHA256, nil
+		case 384:
+			return "ES384", crypto.SHA384, nil
+		case 521:
+			return "ES512", crypto.SHA512, nil
+		}
+	}
+	return "", 0, errors.New("unsupported key of jwt, should be RSA or EC P-256, P-384 or P-521")
+}
+
+func (j *JWT) Credentials(host, port, clientID string) (*Credentials, error) {
+	alg, hash, err := j.algorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if j.now != nil {
+		now = j.now()
+	}
+	claims := map[string]interface{}{
+		"iat": now.Unix(),
+		"exp": now.Add(j.TTL).Unix(),
+	}
+	if j.Audience != "" {
+		claims["aud"] = j.Audience
+	}
+	for k, v := range j.Claims {
+		claims[k] = v
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	h := hash.New()
+	h.Write([]byte(token))
+	digest := h.Sum(nil)
+
+	var sig []byte
+	switch key := j.Key.(type) {
+	case *rsa.PrivateKey:
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest); err != nil {
+			return nil, err
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		//JWS takes r and s as fixed size big-endian integers
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	}
+	return &Credentials{Password: token + "." + base64.RawURLEncoding.EncodeToString(sig)}, nil
+}
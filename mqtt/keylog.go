@@ -0,0 +1,65 @@
+package mqtt
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+//KeyLog writes TLS secrets in the NSS key log format (SSLKEYLOGFILE) that
+//wireshark reads to decrypt captures. Set it as the KeyLogWriter of
+//ClientConfig.TLSConfig, the secrets of every connection are then preceded
+//by a comment naming the client, the round and the addresses
+type KeyLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	last string //scope of the last line written
+}
+
+func NewKeyLog(w io.Writer) *KeyLog {
+	return &KeyLog{w: w}
+}
+
+//Write writes lines out of any scope
+func (k *KeyLog) Write(b []byte) (int, error) {
+	return k.write("", b)
+}
+
+func (k *KeyLog) write(scope string, b []byte) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if scope != k.last {
+		k.last = scope
+		//the comment is written along with the line so that they are not split
+		comment := "# " + scope + "\n"
+		n, err := k.w.Write(append([]byte(comment), b...))
+		if n -= len(comment); n < 0 {
+			n = 0
+		}
+		return n, err
+	}
+	return k.w.Write(b)
+}
+
+//scope returns a writer of k labeling the lines by scope
+func (k *KeyLog) scope(scope string) io.Writer {
+	return scopedKeyLog{k, scope}
+}
+
+type scopedKeyLog struct {
+	k     *KeyLog
+	scope string
+}
+
+func (s scopedKeyLog) Write(b []byte) (int, error) {
+	return s.k.write(s.scope, b)
+}
+
+//keyLogScope labels the tls secrets of c
+func (c *Client) keyLogScope() string {
+	s := fmt.Sprintf("mqttstat client %v", c.clientID)
+	if c.round > 0 {
+		s += fmt.Sprintf(" round %v", c.round)
+	}
+	return s + fmt.Sprintf(" %v -> %v", c.conn.LocalAddr(), c.conn.RemoteAddr())
+}
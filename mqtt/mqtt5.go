@@ -0,0 +1,357 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+//MQTT 5 adds properties to the packets, the ones used here and differing from
+//MQTT 3.1.1 are encoded and decoded in this file, the others are left to paho
+
+const (
+	ProtocolV311 = 4
+	ProtocolV5   = 5
+)
+
+const (
+	authPacket = 15
+
+	propAuthMethod = 0x15
+	propAuthData   = 0x16
+
+	reasonContinueAuth = 0x18
+)
+
+//connackReasons are the reason codes of MQTT 5 CONNACK refusing the connection
+var connackReasons = map[byte]string{
+	0x80: "Unspecified error",
+	0x81: "Malformed Packet",
+	0x82: "Protocol Error",
+	0x83: "Implementation specific error",
+	0x84: "Unsupported Protocol Version",
+	0x85: "Client Identifier not valid",
+	0x86: "Bad User Name or Password",
+	0x87: "Not authorized",
+	0x88: "Server unavailable",
+	0x89: "Server busy",
+	0x8A: "Banned",
+	0x8C: "Bad authentication method",
+	0x90: "Topic Name invalid",
+	0x95: "Packet too large",
+	0x97: "Quota exceeded",
+	0x99: "Payload format invalid",
+	0x9A: "Retain not supported",
+	0x9B: "QoS not supported",
+	0x9C: "Use another server",
+	0x9D: "Server moved",
+	0x9F: "Connection rate exceeded",
+}
+
+//ackReasons are the reason codes of MQTT 5 PUBACK and SUBACK refusing the
+//message or the subscription
+var ackReasons = map[byte]string{
+	0x80: "Unspecified error",
+	0x83: "Implementation specific error",
+	0x87: "Not authorized",
+	0x8F: "Topic Filter invalid",
+	0x90: "Topic Name invalid",
+	0x91: "Packet Identifier in use",
+	0x97: "Quota exceeded",
+	0x99: "Payload format invalid",
+	0x9E: "Shared Subscriptions not supported",
+	0xA1: "Subscription Identifiers not supported",
+	0xA2: "Wildcard Subscriptions not supported",
+}
+
+//propertySizes are the sizes of the fixed size properties, -1 is a variable
+//byte integer, 0 is a string or binary data and 2 strings for the user property
+var propertySizes = map[byte]int{
+	0x01: 1, 0x02: 4, 0x03: 0, 0x08: 0, 0x09: 0, 0x0B: -1, 0x11: 4, 0x12: 0,
+	0x13: 2, 0x15: 0, 0x16: 0, 0x17: 1, 0x18: 4, 0x19: 1, 0x1A: 0, 0x1C: 0,
+	0x1F: 0, 0x21: 2, 0x22: 2, 0x23: 2, 0x24: 1, 0x25: 1, 0x26: 0, 0x27: 4,
+	0x28: 1, 0x29: 1, 0x2A: 1,
+}
+
+//properties are the values of the properties by identifier, the user
+//properties are dropped
+type properties map[byte][]byte
+
+type connackV5 struct {
+	SessionPresent bool
+	ReasonCode     byte
+	Properties     properties
+}
+
+//pubackV5 is PUBACK with the reason code, 0 if it is left out
+type pubackV5 struct {
+	MessageID  uint16
+	ReasonCode byte
+}
+
+type authV5 struct {
+	ReasonCode byte
+	Properties properties
+}
+
+func appendVarint(b []byte, n int) []byte {
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func appendBinary(b []byte, data []byte) []byte {
+	b = append(b, byte(len(data)>>8), byte(len(data)))
+	return append(b, data...)
+}
+
+func appendProperties(b []byte, props ...[]byte) []byte {
+	var all []byte
+	for _, p := range props {
+		all = append(all, p...)
+	}
+	return append(appendVarint(b, len(all)), all...)
+}
+
+//authProperties returns the authentication method and data as properties
+func authProperties(method string, data []byte) []byte {
+	if method == "" {
+		return appendProperties(nil)
+	}
+	props := [][]byte{appendBinary([]byte{propAuthMethod}, []byte(method))}
+	if data != nil {
+		props = append(props, appendBinary([]byte{propAuthData}, data))
+	}
+	return appendProperties(nil, props...)
+}
+
+//packet prepends the fixed header to body
+func packet(header byte, body []byte) []byte {
+	return append(appendVarint([]byte{header}, len(body)), body...)
+}
+
+func connectV5(clientID, username, password string, cleanStart bool, method string, data []byte) []byte {
+	b := appendBinary(nil, []byte("MQTT"))
+	var flags byte
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+	if cleanStart {
+		flags |= 0x02
+	}
+	b = append(b, ProtocolV5, flags, 0, 0) //keep alive is disabled as MQTT 3.1.1 connections
+	b = append(b, authProperties(method, data)...)
+	b = appendBinary(b, []byte(clientID))
+	if username != "" {
+		b = appendBinary(b, []byte(username))
+	}
+	if password != "" {
+		b = appendBinary(b, []byte(password))
+	}
+	return packet(packets.Connect<<4, b)
+}
+
+func authPacketV5(reason byte, method string, data []byte) []byte {
+	b := append([]byte{reason}, authProperties(method, data)...)
+	return packet(authPacket<<4, b)
+}
+
+func publishV5(topic string, id uint16, qos byte, payload []byte) []byte {
+	b := appendBinary(nil, []byte(topic))
+	if qos > 0 {
+		b = append(b, byte(id>>8), byte(id))
+	}
+	b = appendProperties(b)
+	b = append(b, payload...)
+	return packet(packets.Publish<<4|qos<<1, b)
+}
+
+func subscribeV5(id uint16, topics []string, qoss []byte) []byte {
+	b := appendProperties([]byte{byte(id >> 8), byte(id)})
+	for i, topic := range topics {
+		b = appendBinary(b, []byte(topic))
+		b = append(b, qoss[i]) //the options other than qos are zero
+	}
+	return packet(packets.Subscribe<<4|0x02, b)
+}
+
+var errMalformed = errors.New("malformed MQTT 5 packet")
+
+func readVarint(r io.ByteReader) (int, error) {
+	var n, mul int = 0, 1
+	for i := 0; i < 4; i++ {
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(d&0x7F) * mul
+		if d&0x80 == 0 {
+			return n, nil
+		}
+		mul *= 128
+	}
+	return 0, errMalformed
+}
+
+func readBinary(r *bytes.Reader) ([]byte, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, errMalformed
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, errMalformed
+	}
+	return b, nil
+}
+
+func readProperties(r *bytes.Reader) (properties, error) {
+	n, err := readVarint(r)
+	if err != nil || n > r.Len() {
+		return nil, errMalformed
+	}
+	b := make([]byte, n)
+	r.Read(b)
+
+	props := make(properties)
+	pr := bytes.NewReader(b)
+	for pr.Len() > 0 {
+		id, _ := pr.ReadByte()
+		size, found := propertySizes[id]
+		if !found {
+			return nil, fmt.Errorf("unknown MQTT 5 property 0x%02x", id)
+		}
+		var v []byte
+		switch {
+		case size > 0:
+			v = make([]byte, size)
+			if _, err := io.ReadFull(pr, v); err != nil {
+				return nil, errMalformed
+			}
+		case size < 0:
+			d, err := readVarint(pr)
+			if err != nil {
+				return nil, errMalformed
+			}
+			v = appendVarint(nil, d)
+		default:
+			if v, err = readBinary(pr); err != nil {
+				return nil, err
+			}
+			if id == 0x26 {
+				if _, err := readBinary(pr); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		props[id] = v
+	}
+	return props, nil
+}
+
+//readPacketV5 reads a packet of MQTT 5. CONNACK, PUBACK and AUTH are returned as
+//*connackV5, *pubackV5 and *authV5, PUBLISH and SUBACK are returned as the packets of
+//paho without properties, the others are decoded by paho
+func readPacketV5(r io.Reader) (interface{}, error) {
+	var header [1]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n, err := readVarint(byteReader{r})
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	br := bytes.NewReader(body)
+	switch typ := header[0] >> 4; typ {
+	case packets.Connack:
+		if len(body) < 2 {
+			return nil, errMalformed
+		}
+		p := &connackV5{SessionPresent: body[0]&0x01 != 0, ReasonCode: body[1]}
+		if len(body) == 2 {
+			return p, nil //refused by a MQTT 3.1.1 broker
+		}
+		br.Seek(2, io.SeekStart)
+		p.Properties, err = readProperties(br)
+		return p, err
+	case authPacket:
+		p := &authV5{Properties: properties{}}
+		if len(body) == 0 {
+			return p, nil //success without properties
+		}
+		p.ReasonCode, _ = br.ReadByte()
+		if br.Len() > 0 {
+			p.Properties, err = readProperties(br)
+		}
+		return p, err
+	case packets.Publish:
+		p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		p.Qos = header[0] >> 1 & 0x03
+		topic, err := readBinary(br)
+		if err != nil {
+			return nil, err
+		}
+		p.TopicName = string(topic)
+		if p.Qos > 0 {
+			if err := binary.Read(br, binary.BigEndian, &p.MessageID); err != nil {
+				return nil, errMalformed
+			}
+		}
+		if _, err := readProperties(br); err != nil {
+			return nil, err
+		}
+		p.Payload = body[len(body)-br.Len():]
+		return p, nil
+	case packets.Puback:
+		if len(body) < 2 {
+			return nil, errMalformed
+		}
+		p := &pubackV5{MessageID: binary.BigEndian.Uint16(body)}
+		if len(body) > 2 {
+			p.ReasonCode = body[2]
+		}
+		return p, nil
+	case packets.Suback:
+		p := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+		if err := binary.Read(br, binary.BigEndian, &p.MessageID); err != nil {
+			return nil, errMalformed
+		}
+		if _, err := readProperties(br); err != nil {
+			return nil, err
+		}
+		p.ReturnCodes = body[len(body)-br.Len():]
+		return p, nil
+	}
+	return packets.ReadPacket(io.MultiReader(bytes.NewReader(appendVarint(header[:], n)), bytes.NewReader(body)))
+}
+
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
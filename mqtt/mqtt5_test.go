@@ -0,0 +1,137 @@
+package mqtt
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+func TestVarint(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, 268435455} {
+		b := appendVarint(nil, n)
+		got, err := readVarint(bytes.NewReader(b))
+		if err != nil || got != n {
+			t.Errorf("varint %v: got %v, %v from % x", n, got, err, b)
+		}
+	}
+	if _, err := readVarint(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x01})); err == nil {
+		t.Error("varint longer than 4 bytes should be malformed")
+	}
+}
+
+func TestAuthProperties(t *testing.T) {
+	b := authProperties("SCRAM-SHA-256", []byte("n,,n=user,r=abc"))
+	props, err := readProperties(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(props[propAuthMethod]) != "SCRAM-SHA-256" || string(props[propAuthData]) != "n,,n=user,r=abc" {
+		t.Errorf("got properties %q", props)
+	}
+
+	props, err = readProperties(bytes.NewReader(authProperties("", nil)))
+	if err != nil || len(props) != 0 {
+		t.Errorf("got properties %q, %v, want none", props, err)
+	}
+}
+
+func TestReadProperties(t *testing.T) {
+	b := appendProperties(nil,
+		[]byte{0x11, 0, 0, 0x0E, 0x10},                                     //session expiry interval
+		[]byte{0x0B, 0x80, 0x01},                                           //subscription identifier 128
+		appendBinary(appendBinary([]byte{0x26}, []byte("k")), []byte("v")), //user property
+		appendBinary([]byte{0x1F}, []byte("reason")),                       //reason string
+	)
+	props, err := readProperties(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(props[0x11], []byte{0, 0, 0x0E, 0x10}) || !bytes.Equal(props[0x0B], []byte{0x80, 0x01}) ||
+		string(props[0x1F]) != "reason" {
+		t.Errorf("got properties %q", props)
+	}
+	if _, found := props[0x26]; found {
+		t.Error("user properties should be dropped")
+	}
+
+	if _, err := readProperties(bytes.NewReader(appendProperties(nil, []byte{0x7F, 0}))); err == nil {
+		t.Error("unknown property should fail")
+	}
+	if _, err := readProperties(bytes.NewReader([]byte{5, 0x11, 0})); err == nil {
+		t.Error("truncated properties should fail")
+	}
+}
+
+func TestReadPacketV5(t *testing.T) {
+	p, err := readPacketV5(bytes.NewReader(authPacketV5(reasonContinueAuth, "SCRAM-SHA-1", []byte("r=x"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, ok := p.(*authV5)
+	if !ok || auth.ReasonCode != reasonContinueAuth || string(auth.Properties[propAuthMethod]) != "SCRAM-SHA-1" ||
+		string(auth.Properties[propAuthData]) != "r=x" {
+		t.Errorf("got %#v", p)
+	}
+
+	connack := packet(packets.Connack<<4, append([]byte{1, 0x86}, authProperties("SCRAM-SHA-1", []byte("v=y"))...))
+	p, err = readPacketV5(bytes.NewReader(connack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, ok := p.(*connackV5)
+	if !ok || !ca.SessionPresent || ca.ReasonCode != 0x86 || string(ca.Properties[propAuthData]) != "v=y" {
+		t.Errorf("got %#v", p)
+	}
+
+	p, err = readPacketV5(bytes.NewReader(publishV5("a/b", 7, 1, []byte("payload"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, ok := p.(*packets.PublishPacket)
+	if !ok || pub.TopicName != "a/b" || pub.MessageID != 7 || pub.Qos != 1 || string(pub.Payload) != "payload" {
+		t.Errorf("got %#v", p)
+	}
+}
+
+func TestReadPubackV5(t *testing.T) {
+	for _, c := range []struct {
+		body   []byte
+		reason byte
+	}{
+		{[]byte{0, 7}, 0},
+		{[]byte{0, 7, 0x10}, 0x10},
+		{[]byte{0, 7, 0x87, 0}, 0x87},
+	} {
+		p, err := readPacketV5(bytes.NewReader(packet(packets.Puback<<4, c.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ack, ok := p.(*pubackV5); !ok || ack.MessageID != 7 || ack.ReasonCode != c.reason {
+			t.Errorf("% x: got %#v", c.body, p)
+		}
+	}
+}
+
+//a PUBACK refusing the message fails the ack rather than counting as published
+func TestPubackRefused(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	c := NewClient(&ClientConfig{})
+	c.conn, c.v5 = local, true
+	go c.recvHandler()
+
+	ackc := make(chan ACK, 1)
+	c.rr[7] = ackc
+	go remote.Write(packet(packets.Puback<<4, []byte{0, 7, 0x87, 0}))
+
+	ack := <-ackc
+	err, ok := ack.Err.(*AckError)
+	if !ok || err.ReasonCode != 0x87 || err.Error() != "MQTT PUBACK failed: Not authorized" {
+		t.Errorf("got %v", ack.Err)
+	}
+	if ack.PacketID != 7 {
+		t.Errorf("packet id %d, want 7", ack.PacketID)
+	}
+}
@@ -0,0 +1,424 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+const (
+	pcapLinkTypeRaw = 101 //the packets begin with an IPv4 or IPv6 header
+	pcapSnapLen     = 65535
+	pcapSegment     = 32768 //max payload of a synthesized tcp segment
+
+	//PcapPort is the server port of the synthesized tcp stream, so that the
+	//MQTT dissector of wireshark decodes it without "Decode As"
+	PcapPort = 1883
+)
+
+const (
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpPSH = 0x08
+	tcpACK = 0x10
+)
+
+//PcapWriter writes the plaintext MQTT stream of the clients to a pcap file
+//with synthesized IP and TCP headers. It is safe to be shared by clients
+type PcapWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+//NewPcapWriter writes the pcap file header to w
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkTypeRaw)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+func (p *PcapWriter) writePacket(t time.Time, data []byte) error {
+	record := make([]byte, 16, 16+len(data))
+	binary.LittleEndian.PutUint32(record[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(data)))
+	record = append(record, data...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if _, err := p.w.Write(record); err != nil {
+		//the later packets are dropped, the stream could not be decoded anyway
+		p.err = fmt.Errorf("pcap: %v", err)
+	}
+	return p.err
+}
+
+//pcapConn writes what is read and written to the pcap as tcp segments
+type pcapConn struct {
+	net.Conn
+	pcap *PcapWriter
+
+	mu            sync.Mutex
+	local, remote *net.TCPAddr
+	seq, ack      uint32 //next sequence number of the local and the remote side
+	closed        bool
+
+	//the packets sent are decoded to mask their secrets
+	pending []byte //the head of a packet not written yet
+	skip    int    //bytes left of the packet being written
+}
+
+//newPcapConn synthesizes the three-way handshake of the stream, the
+//addresses of the connection are kept if they are tcp, or else loopback
+//addresses are used. Failing to write the pcap fails the connection
+func newPcapConn(conn net.Conn, pcap *PcapWriter) (*pcapConn, error) {
+	local, _ := conn.LocalAddr().(*net.TCPAddr)
+	remote, _ := conn.RemoteAddr().(*net.TCPAddr)
+	if local == nil || remote == nil || (local.IP.To4() == nil) != (remote.IP.To4() == nil) {
+		local = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 49152 + rand.Intn(16384)}
+		remote = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	}
+	remote = &net.TCPAddr{IP: remote.IP, Port: PcapPort}
+
+	pc := &pcapConn{Conn: conn, pcap: pcap, local: local, remote: remote, seq: rand.Uint32(), ack: rand.Uint32()}
+	now := time.Now()
+	if err := pc.segment(now, true, tcpSYN, nil); err != nil {
+		return nil, err
+	}
+	pc.seq++
+	if err := pc.segment(now, false, tcpSYN|tcpACK, nil); err != nil {
+		return nil, err
+	}
+	pc.ack++
+	if err := pc.segment(now, true, tcpACK, nil); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+func (pc *pcapConn) Read(b []byte) (int, error) {
+	n, err := pc.Conn.Read(b)
+	if n > 0 {
+		if perr := pc.data(false, b[:n]); perr != nil {
+			return n, perr
+		}
+	}
+	return n, err
+}
+
+func (pc *pcapConn) Write(b []byte) (int, error) {
+	n, err := pc.Conn.Write(b)
+	if n > 0 {
+		if perr := pc.data(true, b[:n]); perr != nil {
+			return n, perr
+		}
+	}
+	return n, err
+}
+
+func (pc *pcapConn) Close() error {
+	var perr error
+	pc.mu.Lock()
+	if !pc.closed {
+		pc.closed = true
+		perr = pc.segment(time.Now(), true, tcpFIN|tcpACK, nil)
+		pc.seq++
+	}
+	pc.mu.Unlock()
+	if err := pc.Conn.Close(); err != nil {
+		return err
+	}
+	return perr
+}
+
+//mask returns the bytes of b to be written to the pcap, CONNECT and AUTH are
+//held until they are complete and written with their secrets masked
+func (pc *pcapConn) mask(b []byte) []byte {
+	var out []byte
+	for len(b) > 0 {
+		if pc.skip > 0 {
+			n := len(b)
+			if n > pc.skip {
+				n = pc.skip
+			}
+			out = append(out, b[:n]...)
+			pc.skip -= n
+			b = b[n:]
+			continue
+		}
+
+		pc.pending = append(pc.pending, b...)
+		size, found := packetSize(pc.pending)
+		if !found {
+			break
+		}
+		if typ := pc.pending[0] >> 4; typ != packets.Connect && typ != authPacket {
+			n := len(pc.pending)
+			if n > size {
+				n = size
+			}
+			out = append(out, pc.pending[:n]...)
+			pc.skip = size - n
+			b, pc.pending = pc.pending[n:], nil
+			continue
+		}
+		if len(pc.pending) < size {
+			break
+		}
+		out = append(out, maskPacket(pc.pending[:size])...)
+		b, pc.pending = pc.pending[size:], nil
+	}
+	return out
+}
+
+//packetSize is the size of the packet p begins with, false if its fixed
+//header is not complete. A malformed length takes the whole of p
+func packetSize(p []byte) (int, bool) {
+	n, mul := 0, 1
+	for i := 1; i < len(p); i++ {
+		n += int(p[i]&0x7F) * mul
+		if p[i]&0x80 == 0 {
+			return i + 1 + n, true
+		}
+		if i == 4 {
+			return len(p), true
+		}
+		mul *= 128
+	}
+	return 0, false
+}
+
+//maskPacket returns a copy of the packet p with the password of CONNECT and
+//the authentication data of CONNECT and AUTH replaced by '*'. A malformed
+//packet is masked from where it is not decoded
+func maskPacket(p []byte) []byte {
+	m := &masker{b: append([]byte(nil), p...), i: 1}
+	m.varint()
+	switch p[0] >> 4 {
+	case packets.Connect:
+		m.binary(false) //protocol name
+		level, flags := m.byte(), m.byte()
+		m.skip(2) //keepalive
+		if level == ProtocolV5 {
+			m.properties()
+		}
+		m.binary(false) //client id
+		if flags&0x04 != 0 {
+			if level == ProtocolV5 {
+				m.properties()
+			}
+			m.binary(false) //will topic
+			m.binary(false) //will message
+		}
+		if flags&0x80 != 0 {
+			m.binary(false) //username
+		}
+		if flags&0x40 != 0 {
+			m.binary(true)
+		}
+	case authPacket:
+		if m.i < len(m.b) {
+			m.byte() //reason code
+			m.properties()
+		}
+	}
+	if m.bad {
+		for i := m.i; i < len(m.b); i++ {
+			m.b[i] = '*'
+		}
+	}
+	return m.b
+}
+
+//masker decodes a packet from b[i], bad is set once it is malformed
+type masker struct {
+	b   []byte
+	i   int
+	bad bool
+}
+
+func (m *masker) skip(n int) {
+	if m.bad || n < 0 || m.i+n > len(m.b) {
+		m.bad = true
+		return
+	}
+	m.i += n
+}
+
+func (m *masker) byte() byte {
+	if m.bad || m.i >= len(m.b) {
+		m.bad = true
+		return 0
+	}
+	m.i++
+	return m.b[m.i-1]
+}
+
+func (m *masker) varint() int {
+	n, mul := 0, 1
+	for i := 0; i < 4; i++ {
+		d := m.byte()
+		n += int(d&0x7F) * mul
+		if d&0x80 == 0 {
+			return n
+		}
+		mul *= 128
+	}
+	m.bad = true
+	return 0
+}
+
+//binary skips a string or binary data, masking it if mask is true
+func (m *masker) binary(mask bool) {
+	n := int(m.byte())<<8 | int(m.byte())
+	begin := m.i
+	m.skip(n)
+	if mask && !m.bad {
+		for i := begin; i < m.i; i++ {
+			m.b[i] = '*'
+		}
+	}
+}
+
+//properties skips the properties, masking the authentication data
+func (m *masker) properties() {
+	n := m.varint()
+	end := m.i + n
+	for !m.bad && m.i < end {
+		id := m.byte()
+		size, found := propertySizes[id]
+		switch {
+		case !found:
+			m.bad = true
+		case size > 0:
+			m.skip(size)
+		case size < 0:
+			m.varint()
+		default:
+			m.binary(id == propAuthData)
+			if id == 0x26 {
+				m.binary(false) //value of the user property
+			}
+		}
+	}
+	if m.i > end {
+		m.bad = true
+	}
+}
+
+func (pc *pcapConn) data(sent bool, b []byte) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if sent {
+		b = pc.mask(b)
+	}
+	now := time.Now()
+	for len(b) > 0 {
+		n := len(b)
+		if n > pcapSegment {
+			n = pcapSegment
+		}
+		if err := pc.segment(now, sent, tcpPSH|tcpACK, b[:n]); err != nil {
+			return err
+		}
+		if sent {
+			pc.seq += uint32(n)
+		} else {
+			pc.ack += uint32(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+//segment writes a tcp segment from the local side if sent is true, or else from the remote side
+func (pc *pcapConn) segment(t time.Time, sent bool, flags byte, payload []byte) error {
+	src, dst, seq, ack := pc.local, pc.remote, pc.seq, pc.ack
+	if !sent {
+		src, dst, seq, ack = pc.remote, pc.local, pc.ack, pc.seq
+	}
+
+	tcp := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(tcp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	if flags&tcpACK != 0 {
+		binary.BigEndian.PutUint32(tcp[8:], ack)
+	}
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+	tcp = append(tcp, payload...)
+
+	var ip, pseudo []byte
+	if src4, dst4 := src.IP.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		ip = make([]byte, 20)
+		ip[0] = 4<<4 | 5
+		binary.BigEndian.PutUint16(ip[2:], uint16(len(ip)+len(tcp)))
+		ip[8] = 64
+		ip[9] = 6
+		copy(ip[12:], src4)
+		copy(ip[16:], dst4)
+		binary.BigEndian.PutUint16(ip[10:], checksum(ip, 0))
+
+		pseudo = make([]byte, 12)
+		copy(pseudo[0:], src4)
+		copy(pseudo[4:], dst4)
+		pseudo[9] = 6
+		binary.BigEndian.PutUint16(pseudo[10:], uint16(len(tcp)))
+	} else {
+		ip = make([]byte, 40)
+		ip[0] = 6 << 4
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(tcp)))
+		ip[6] = 6
+		ip[7] = 64
+		copy(ip[8:], src.IP.To16())
+		copy(ip[24:], dst.IP.To16())
+
+		pseudo = make([]byte, 40)
+		copy(pseudo[0:], src.IP.To16())
+		copy(pseudo[16:], dst.IP.To16())
+		binary.BigEndian.PutUint32(pseudo[32:], uint32(len(tcp)))
+		pseudo[39] = 6
+	}
+	binary.BigEndian.PutUint16(tcp[16:], checksum(tcp, sum(pseudo, 0)))
+
+	return pc.pcap.writePacket(t, append(ip, tcp...))
+}
+
+//sum adds b to s as 16-bit words in ones' complement
+func sum(b []byte, s uint32) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		s += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		s += uint32(b[len(b)-1]) << 8
+	}
+	return s
+}
+
+func checksum(b []byte, s uint32) uint16 {
+	s = sum(b, s)
+	for s > 0xffff {
+		s = s>>16 + s&0xffff
+	}
+	return ^uint16(s)
+}
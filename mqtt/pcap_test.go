@@ -0,0 +1,158 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+func TestPcapHeader(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if _, err := NewPcapWriter(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "d4c3b2a1020004000000000000000000ffff000065000000"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("header %s, want %s", got, want)
+	}
+}
+
+//the records of a PINGREQ sent over IPv4 and a PINGRESP received over IPv6,
+//the checksums are computed independently
+func TestPcapSegment(t *testing.T) {
+	cases := []struct {
+		name          string
+		local, remote *net.TCPAddr
+		sent          bool
+		payload       []byte
+		want          string
+	}{
+		{
+			name:    "ipv4",
+			local:   &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000},
+			remote:  &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: PcapPort},
+			sent:    true,
+			payload: []byte{0xc0, 0x00},
+			want: "00f1536540e201002a0000002a000000" +
+				"4500002a0000000040068e98c0000201c6336401" +
+				"c350075b000003e8000007d05018ffff2d300000" + "c000",
+		},
+		{
+			name:    "ipv6",
+			local:   &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 50000},
+			remote:  &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: PcapPort},
+			sent:    false,
+			payload: []byte{0xd0, 0x00},
+			want: "00f1536540e201003e0000003e000000" +
+				"600000000016064020010db800000000000000000000000220010db8000000000000000000000001" +
+				"075bc350000007d0000003ea5018ffffadef0000" + "d000",
+		},
+	}
+	for _, c := range cases {
+		buf := bytes.NewBuffer(nil)
+		pc := &pcapConn{pcap: &PcapWriter{w: buf}, local: c.local, remote: c.remote, seq: 1002, ack: 2000}
+		if c.sent {
+			pc.seq = 1000
+		}
+		if err := pc.segment(time.Unix(1700000000, 123456000), c.sent, tcpPSH|tcpACK, c.payload); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != c.want {
+			t.Errorf("%s record\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+type failedWriter struct{}
+
+func (failedWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestPcapWriteError(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	go func() {
+		b := make([]byte, 16)
+		remote.Read(b)
+	}()
+
+	if _, err := newPcapConn(local, &PcapWriter{w: failedWriter{}}); err == nil {
+		t.Error("failing to write the handshake should fail")
+	}
+
+	w := &PcapWriter{w: bytes.NewBuffer(nil)}
+	pc, err := newPcapConn(local, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.w = failedWriter{}
+	if _, err := pc.Write([]byte{0xc0, 0x00}); err == nil {
+		t.Error("failing to write the segment should fail the write")
+	}
+}
+
+func encode(p packets.ControlPacket) []byte {
+	buf := bytes.NewBuffer(nil)
+	p.Write(buf)
+	return buf.Bytes()
+}
+
+//the password and the authentication data are masked, the same bytes in the
+//other fields and packets are kept
+func TestPcapMask(t *testing.T) {
+	connect := packets.NewControlPacket(packets.Connect).(*packets.ConnectPacket)
+	connect.ProtocolName, connect.ProtocolVersion = "MQTT", ProtocolV311
+	connect.ClientIdentifier, connect.Keepalive = "c0", 0x3030
+	connect.UsernameFlag, connect.Username = true, "u0"
+	connect.PasswordFlag, connect.Password = true, []byte("0")
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.TopicName, publish.Qos, publish.MessageID, publish.Payload = "t0", 1, 0x3030, []byte("A0B0")
+
+	connect311 := encode(connect)
+	publish311 := encode(publish)
+	connect5 := connectV5("c", "user", "pencil", true, "SCRAM-SHA-256", []byte("n,,n=user,r=nonce"))
+	auth5 := authPacketV5(reasonContinueAuth, "SCRAM-SHA-256", []byte("c=biws,p=proof"))
+	publish5 := publishV5("pencil", 1, 1, []byte("pencil proof"))
+
+	stream := bytes.Join([][]byte{connect311, publish311, connect5, auth5, publish5}, nil)
+	want := bytes.Join([][]byte{
+		append(connect311[:len(connect311)-1:len(connect311)-1], '*'),
+		publish311,
+		bytes.Replace(bytes.Replace(connect5, []byte("n,,n=user,r=nonce"), []byte("*****************"), 1), []byte("pencil"), []byte("******"), 1),
+		bytes.Replace(auth5, []byte("c=biws,p=proof"), []byte("**************"), 1),
+		publish5,
+	}, nil)
+
+	for _, size := range []int{1, 3, 7, len(stream)} {
+		pc := &pcapConn{}
+		var got []byte
+		for b := stream; len(b) > 0; {
+			n := size
+			if n > len(b) {
+				n = len(b)
+			}
+			got = append(got, pc.mask(b[:n])...)
+			b = b[n:]
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("written in %d bytes\n%q\nwant\n%q", size, got, want)
+		}
+	}
+	if !bytes.Contains(stream, []byte("A0B0")) || !bytes.Contains(stream, []byte("pencil")) {
+		t.Error("the stream should be left as it is")
+	}
+}
+
+func TestPcapMaskMalformed(t *testing.T) {
+	//the password length runs beyond the packet
+	got := maskPacket([]byte{0x10, 0x0e, 0, 4, 'M', 'Q', 'T', 'T', 4, 0x40, 0, 0, 0, 0, 0, 9, 's', 'e'})
+	if !bytes.HasSuffix(got, []byte("**")) {
+		t.Errorf("malformed password should be masked, got %q", got)
+	}
+}
@@ -0,0 +1,215 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//dialProxy connects to the proxy and establishes a tunnel to host:port
+//through it, the host is resolved by the proxy
+func (c *Client) dialProxy(d net.Dialer, host, port string) (net.Conn, error) {
+	u, err := url.Parse(c.cfg.Proxy)
+	if err != nil {
+		return nil, stripURL(err)
+	}
+	if c.ip != "" {
+		host = c.ip
+	}
+
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "socks5":
+			proxyAddr = net.JoinHostPort(u.Hostname(), "1080")
+		case "http":
+			proxyAddr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	if c.tracer != nil {
+		c.tracer.AddPoint(TraceProxyDial, time.Now())
+	}
+	conn, err := c.dialContext(context.Background(), d, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tracer != nil {
+		c.tracer.AddPoint(TraceProxyTunnel, time.Now())
+	}
+	switch u.Scheme {
+	case "socks5":
+		err = socks5Connect(conn, u.User, host, port)
+	case "http":
+		conn, err = httpConnect(conn, u.User, host, port)
+	default:
+		err = errors.New("unsupported proxy scheme " + u.Scheme + ", should be socks5 or http")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+//socks5Connect sends a CONNECT request as RFC 1928 with the username/password
+//authentication of RFC 1929 if user is not nil
+func socks5Connect(conn net.Conn, user *url.Userinfo, host, port string) error {
+	const (
+		version       = 0x05
+		noAuth        = 0x00
+		userPassAuth  = 0x02
+		noAcceptable  = 0xFF
+		cmdConnect    = 0x01
+		atypIPv4      = 0x01
+		atypDomain    = 0x03
+		atypIPv6      = 0x04
+		userPassVer   = 0x01
+		replySucceded = 0x00
+	)
+
+	methods := []byte{noAuth}
+	if user != nil {
+		methods = []byte{userPassAuth}
+	}
+	if _, err := conn.Write(append([]byte{version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[0] != version {
+		return errors.New("socks5: unexpected version " + strconv.Itoa(int(resp[0])))
+	}
+	switch resp[1] {
+	case noAuth:
+	case userPassAuth:
+		password, _ := user.Password()
+		name := user.Username()
+		if len(name) > 255 || len(password) > 255 {
+			return errors.New("socks5: username or password is too long")
+		}
+		req := []byte{userPassVer, byte(len(name))}
+		req = append(req, name...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, resp[:]); err != nil {
+			return err
+		}
+		if resp[1] != 0 {
+			return errors.New("socks5: authentication failed")
+		}
+	case noAcceptable:
+		return errors.New("socks5: no acceptable authentication method")
+	default:
+		return errors.New("socks5: unsupported authentication method " + strconv.Itoa(int(resp[1])))
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return err
+	}
+	req := []byte{version, cmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errors.New("socks5: host is too long")
+		}
+		req = append(req, atypDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, atypIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, atypIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(p>>8), byte(p))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != replySucceded {
+		return errors.New("socks5: connect failed with reply " + strconv.Itoa(int(reply[1])))
+	}
+
+	//skip the bound address
+	var l int
+	switch reply[3] {
+	case atypIPv4:
+		l = net.IPv4len
+	case atypIPv6:
+		l = net.IPv6len
+	case atypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return err
+		}
+		l = int(n[0])
+	default:
+		return errors.New("socks5: unknown address type " + strconv.Itoa(int(reply[3])))
+	}
+	bound := make([]byte, l+2)
+	_, err = io.ReadFull(conn, bound)
+	return err
+}
+
+//bufferedConn reads the bytes buffered when reading the response of CONNECT first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+//httpConnect establishes a tunnel by the CONNECT method of http
+func httpConnect(conn net.Conn, user *url.Userinfo, host, port string) (net.Conn, error) {
+	addr := net.JoinHostPort(host, port)
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("http proxy: CONNECT failed: " + resp.Status)
+	}
+
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
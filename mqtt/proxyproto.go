@@ -0,0 +1,172 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+)
+
+//Type of the TLVs defined by PROXY protocol v2
+const (
+	PP2TypeALPN      = 0x01
+	PP2TypeAuthority = 0x02
+	PP2TypeCRC32C    = 0x03
+	PP2TypeNoop      = 0x04
+	PP2TypeUniqueID  = 0x05
+)
+
+//TLV is a type-length-value vector appended to a PROXY protocol v2 header
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+//ProxyProtocolConfig sends a PROXY protocol header before any other bytes of
+//the connection, as what a load balancer does
+type ProxyProtocolConfig struct {
+	Version     int    //1 or 2, 0 disables the header
+	Source      string //ip:port, the local address of the connection if it is empty
+	Destination string //ip:port, the remote address of the connection if it is empty
+	TLVs        []TLV  //v2 only, the value of PP2TypeCRC32C is computed
+}
+
+var proxyProtocolV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+func splitAddr(addr string) (net.IP, uint16, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, errors.New("invalid ip " + host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ip, uint16(p), nil
+}
+
+//proxyAddr returns the address set by user or the address of a tcp connection,
+//ip is nil if neither of them is available, like the addresses of unix sockets
+func proxyAddr(set string, conn net.Addr) (net.IP, uint16, error) {
+	if set != "" {
+		return splitAddr(set)
+	}
+	if addr, ok := conn.(*net.TCPAddr); ok {
+		return addr.IP, uint16(addr.Port), nil
+	}
+	return nil, 0, nil
+}
+
+//Header of the connection from local to remote, the address family is
+//unknown if the addresses are not of tcp. It fails if the addresses are of
+//different families or if TLVs are given to v1
+func (cfg *ProxyProtocolConfig) Header(local, remote net.Addr) ([]byte, error) {
+	srcIP, srcPort, err := proxyAddr(cfg.Source, local)
+	if err != nil {
+		return nil, err
+	}
+	dstIP, dstPort, err := proxyAddr(cfg.Destination, remote)
+	if err != nil {
+		return nil, err
+	}
+	known := srcIP != nil && dstIP != nil
+	v4 := known && srcIP.To4() != nil && dstIP.To4() != nil
+	v6 := known && srcIP.To4() == nil && dstIP.To4() == nil
+	if known && !v4 && !v6 {
+		return nil, errors.New("source " + srcIP.String() + " and destination " + dstIP.String() +
+			" of PROXY protocol header should be of the same family")
+	}
+	if cfg.Version == 1 && len(cfg.TLVs) > 0 {
+		return nil, errors.New("TLVs need PROXY protocol v2")
+	}
+
+	switch cfg.Version {
+	case 1:
+		proto := "UNKNOWN"
+		switch {
+		case v4:
+			proto = "TCP4"
+		case v6:
+			proto = "TCP6"
+		}
+		if proto == "UNKNOWN" {
+			return []byte("PROXY UNKNOWN\r\n"), nil
+		}
+		return []byte("PROXY " + proto + " " + srcIP.String() + " " + dstIP.String() + " " +
+			strconv.Itoa(int(srcPort)) + " " + strconv.Itoa(int(dstPort)) + "\r\n"), nil
+	case 2:
+		const (
+			cmdProxy    = 0x21 //version 2, PROXY
+			famTCP4     = 0x11
+			famTCP6     = 0x21
+			famUnspec   = 0x00
+			maxTLVValue = 0xFFFF
+		)
+
+		var addrs bytes.Buffer
+		fam := byte(famUnspec)
+		switch {
+		case v4:
+			fam = famTCP4
+			addrs.Write(srcIP.To4())
+			addrs.Write(dstIP.To4())
+		case v6:
+			fam = famTCP6
+			addrs.Write(srcIP.To16())
+			addrs.Write(dstIP.To16())
+		}
+		if fam != famUnspec {
+			binary.Write(&addrs, binary.BigEndian, srcPort)
+			binary.Write(&addrs, binary.BigEndian, dstPort)
+		}
+		crc := -1 //offset of the crc32c value in addrs
+		for _, tlv := range cfg.TLVs {
+			value := tlv.Value
+			if tlv.Type == PP2TypeCRC32C {
+				//computed over the whole header with the value zeroed
+				value = make([]byte, 4)
+				crc = addrs.Len() + 3
+			}
+			if len(value) > maxTLVValue {
+				return nil, errors.New("value of tlv " + strconv.Itoa(int(tlv.Type)) + " is too long")
+			}
+			addrs.WriteByte(tlv.Type)
+			binary.Write(&addrs, binary.BigEndian, uint16(len(value)))
+			addrs.Write(value)
+		}
+		if addrs.Len() > 0xFFFF {
+			return nil, errors.New("PROXY protocol header is too long")
+		}
+
+		h := bytes.NewBuffer(nil)
+		h.Write(proxyProtocolV2Sig)
+		h.WriteByte(cmdProxy)
+		h.WriteByte(fam)
+		binary.Write(h, binary.BigEndian, uint16(addrs.Len()))
+		h.Write(addrs.Bytes())
+		b := h.Bytes()
+		if crc >= 0 {
+			off := len(b) - addrs.Len() + crc
+			binary.BigEndian.PutUint32(b[off:], crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)))
+		}
+		return b, nil
+	}
+	return nil, errors.New("unsupported PROXY protocol version " + strconv.Itoa(cfg.Version))
+}
+
+//writeHeader writes the PROXY protocol header of conn to w
+func (cfg *ProxyProtocolConfig) writeHeader(w io.Writer, conn net.Conn) error {
+	h, err := cfg.Header(conn.LocalAddr(), conn.RemoteAddr())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(h)
+	return err
+}
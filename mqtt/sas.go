@@ -0,0 +1,49 @@
+package mqtt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+//SAS generates a shared access signature token as the password, like Azure
+//IoT Hub. The username of Azure is {host}/{device id}/?api-version=2021-04-12
+type SAS struct {
+	Resource   string //resource uri, {host}/devices/{client id} by default
+	Key        string //shared access key in base64
+	PolicyName string //name of the shared access policy, empty for device keys
+	TTL        time.Duration
+
+	now func() time.Time //time.Now by default
+}
+
+func (s *SAS) Credentials(host, port, clientID string) (*Credentials, error) {
+	key, err := base64.StdEncoding.DecodeString(s.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shared access key: %v", err)
+	}
+
+	resource := s.Resource
+	if resource == "" {
+		resource = host + "/devices/" + clientID
+	}
+	resource = url.QueryEscape(resource)
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	expiry := fmt.Sprint(now().Add(s.TTL).Unix())
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(resource + "\n" + expiry))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	token := "SharedAccessSignature sr=" + resource + "&sig=" + url.QueryEscape(sig) + "&se=" + expiry
+	if s.PolicyName != "" {
+		token += "&skn=" + url.QueryEscape(s.PolicyName)
+	}
+	return &Credentials{Password: token}, nil
+}
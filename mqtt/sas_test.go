@@ -0,0 +1,36 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSASCredentials(t *testing.T) {
+	s := &SAS{
+		Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		TTL: time.Hour,
+		now: func() time.Time { return time.Unix(1700000000, 0) },
+	}
+	want := "SharedAccessSignature sr=myhub.azure-devices.net%2Fdevices%2Fdevice1&sig=shMHCsrZXs7borDG9NGTq8IrxskKBJkfxJiGGbdoL8w%3D&se=1700003600"
+
+	creds, err := s.Credentials("myhub.azure-devices.net", "8883", "device1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Password != want {
+		t.Errorf("token %s, want %s", creds.Password, want)
+	}
+
+	s.PolicyName = "iothubowner"
+	if creds, err = s.Credentials("myhub.azure-devices.net", "8883", "device1"); err != nil {
+		t.Fatal(err)
+	}
+	if creds.Password != want+"&skn=iothubowner" {
+		t.Errorf("token %s, want the policy name", creds.Password)
+	}
+
+	s.Key = "not base64"
+	if _, err := s.Credentials("myhub.azure-devices.net", "8883", "device1"); err == nil {
+		t.Error("invalid key should fail")
+	}
+}
@@ -0,0 +1,145 @@
+package mqtt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+//Authenticator runs the enhanced authentication of MQTT 5 by AUTH packets,
+//one is created for every connection
+type Authenticator interface {
+	//Method is the authentication method, like SCRAM-SHA-256
+	Method() string
+	//Start returns the authentication data of CONNECT
+	Start(username, password string) ([]byte, error)
+	//Next returns the data answering the challenge of an AUTH packet
+	Next(challenge []byte) ([]byte, error)
+	//Finish verifies the authentication data of CONNACK
+	Finish(data []byte) error
+}
+
+//SCRAM authenticates by SCRAM-SHA-1 or SCRAM-SHA-256 of RFC 5802 and RFC
+//7677 without channel binding, the password is not normalized by SASLprep
+type SCRAM struct {
+	method string
+	hash   func() hash.Hash
+
+	password        string
+	nonce           string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func NewSCRAMSHA1() Authenticator {
+	return &SCRAM{method: "SCRAM-SHA-1", hash: sha1.New}
+}
+
+func NewSCRAMSHA256() Authenticator {
+	return &SCRAM{method: "SCRAM-SHA-256", hash: sha256.New}
+}
+
+func (s *SCRAM) Method() string {
+	return s.method
+}
+
+func (s *SCRAM) Start(username, password string) ([]byte, error) {
+	if s.nonce == "" {
+		b := make([]byte, 18)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s.nonce = base64.StdEncoding.EncodeToString(b)
+	}
+	s.password = password
+	name := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+	s.clientFirstBare = "n=" + name + ",r=" + s.nonce
+	return []byte("n,," + s.clientFirstBare), nil
+}
+
+//scramAttributes parses the attributes like r=...,s=...,i=...
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range strings.Split(msg, ",") {
+		if len(kv) > 2 && kv[1] == '=' {
+			attrs[kv[:1]] = kv[2:]
+		}
+	}
+	return attrs
+}
+
+func (s *SCRAM) hmac(key []byte, data string) []byte {
+	mac := hmac.New(s.hash, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+//saltedPassword is Hi() of RFC 5802, PBKDF2 with a single block
+func (s *SCRAM) saltedPassword(salt []byte, iterations int) []byte {
+	u := s.hmac([]byte(s.password), string(salt)+"\x00\x00\x00\x01")
+	result := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		u = s.hmac([]byte(s.password), string(u))
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func (s *SCRAM) Next(challenge []byte) ([]byte, error) {
+	serverFirst := string(challenge)
+	attrs := scramAttributes(serverFirst)
+	if e, found := attrs["e"]; found {
+		return nil, errors.New("scram: " + e)
+	}
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return nil, errors.New("scram: invalid nonce of server")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, errors.New("scram: invalid salt of server")
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, errors.New("scram: invalid iteration count of server")
+	}
+
+	withoutProof := "c=biws,r=" + nonce //biws is "n,," in base64
+	authMessage := s.clientFirstBare + "," + serverFirst + "," + withoutProof
+
+	salted := s.saltedPassword(salt, iterations)
+	clientKey := s.hmac(salted, "Client Key")
+	h := s.hash()
+	h.Write(clientKey)
+	signature := s.hmac(h.Sum(nil), authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range proof {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+	s.serverSignature = s.hmac(s.hmac(salted, "Server Key"), authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (s *SCRAM) Finish(data []byte) error {
+	if s.serverSignature == nil {
+		return errors.New("scram: connected before the authentication finished")
+	}
+	attrs := scramAttributes(string(data))
+	if e, found := attrs["e"]; found {
+		return errors.New("scram: " + e)
+	}
+	v, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(v, s.serverSignature) {
+		return errors.New("scram: invalid signature of server")
+	}
+	return nil
+}
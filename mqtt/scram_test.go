@@ -0,0 +1,95 @@
+package mqtt
+
+import (
+	"testing"
+)
+
+//the example exchanges of RFC 5802 section 5 and RFC 7677 section 3
+var scramExchanges = []struct {
+	name        string
+	new         func() Authenticator
+	nonce       string
+	serverFirst string
+	clientFinal string
+	serverFinal string
+}{
+	{
+		name:        "SCRAM-SHA-1",
+		new:         NewSCRAMSHA1,
+		nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+		serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+		clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+		serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+	},
+	{
+		name:        "SCRAM-SHA-256",
+		new:         NewSCRAMSHA256,
+		nonce:       "rOprNGfwEbeRWgbNEkqO",
+		serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+		clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+	},
+}
+
+//startSCRAM starts the exchange of user with a fixed client nonce
+func startSCRAM(t *testing.T, new func() Authenticator, nonce string) *SCRAM {
+	s := new().(*SCRAM)
+	s.nonce = nonce
+	first, err := s.Start("user", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "n,,n=user,r=" + nonce; string(first) != want {
+		t.Fatalf("client first is %q, want %q", first, want)
+	}
+	return s
+}
+
+func TestSCRAM(t *testing.T) {
+	for _, e := range scramExchanges {
+		s := startSCRAM(t, e.new, e.nonce)
+		if s.Method() != e.name {
+			t.Errorf("method is %v, want %v", s.Method(), e.name)
+		}
+		final, err := s.Next([]byte(e.serverFirst))
+		if err != nil {
+			t.Fatalf("%v: %v", e.name, err)
+		}
+		if string(final) != e.clientFinal {
+			t.Errorf("%v: client final is %q, want %q", e.name, final, e.clientFinal)
+		}
+		if err := s.Finish([]byte(e.serverFinal)); err != nil {
+			t.Errorf("%v: %v", e.name, err)
+		}
+	}
+}
+
+func TestSCRAMRejects(t *testing.T) {
+	e := scramExchanges[1]
+
+	s := startSCRAM(t, e.new, e.nonce)
+	if _, err := s.Next([]byte(e.serverFirst)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Finish([]byte("v=rmF9pqV8S7suAoZWja4dJRkFsKQ=")); err == nil {
+		t.Error("a wrong server signature should be rejected")
+	}
+
+	s = startSCRAM(t, e.new, e.nonce)
+	if err := s.Finish([]byte(e.serverFinal)); err == nil {
+		t.Error("finishing before the challenge should be rejected")
+	}
+
+	challenges := []string{
+		"r=" + e.nonce + ",s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", //the server did not add its nonce
+		"r=another,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+		"r=" + e.nonce + "x,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=0",
+		"e=unknown-user",
+	}
+	for _, c := range challenges {
+		s = startSCRAM(t, e.new, e.nonce)
+		if _, err := s.Next([]byte(c)); err == nil {
+			t.Errorf("challenge %q should be rejected", c)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mqtt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const SigV4Service = "iotdevicegateway"
+
+//SigV4 presigns a websocket url by AWS Signature Version 4 with static
+//credentials, like AWS IoT Core
+type SigV4 struct {
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Service      string //SigV4Service by default
+	Path         string //"/mqtt" by default
+
+	now func() time.Time //time.Now by default
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+//signingKey derives the key signing the requests of date, region and service
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+//presign adds the authentication parameters to query and returns it with the
+//signature of a GET of path on host at t, payloadHash is the hex sha256 of
+//the body or UNSIGNED-PAYLOAD
+func (s *SigV4) presign(host, path, service string, query url.Values, payloadHash string, t time.Time) string {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := t.UTC().Format("20060102")
+	scope := date + "/" + s.Region + "/" + service + "/aws4_request"
+
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonicalQuery := strings.Replace(query.Encode(), "+", "%20", -1)
+
+	canonicalRequest := strings.Join([]string{"GET", path, canonicalQuery, "host:" + host, "", "host", payloadHash}, "\n")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(signingKey(s.SecretKey, date, s.Region, service), stringToSign))
+	return canonicalQuery + "&X-Amz-Signature=" + signature
+}
+
+//Credentials presigns the url of wss://host:port, the port is left out if it is 443
+func (s *SigV4) Credentials(host, port, clientID string) (*Credentials, error) {
+	service, path := s.Service, s.Path
+	if service == "" {
+		service = SigV4Service
+	}
+	if path == "" {
+		path = "/mqtt"
+	}
+	if port != "443" {
+		host += ":" + port
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	u := WSSScheme + host + path + "?" + s.presign(host, path, service, url.Values{}, sha256Hex(""), now())
+	if s.SessionToken != "" {
+		//AWS IoT takes the token out of the signature
+		u += "&X-Amz-Security-Token=" + url.QueryEscape(s.SessionToken)
+	}
+	return &Credentials{URL: u}, nil
+}
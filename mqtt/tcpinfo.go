@@ -0,0 +1,39 @@
+package mqtt
+
+import (
+	"time"
+)
+
+//TCPInfo is the kernel-level metrics of a tcp connection, read by TCP_INFO
+type TCPInfo struct {
+	Label        string //when it is read
+	Time         time.Time
+	RTT          time.Duration //smoothed round trip time
+	RTTVar       time.Duration
+	Retransmits  uint32 //total retransmitted segments
+	Lost         uint32
+	SendCwnd     uint32 //in segments
+	SendMSS      uint32
+	RecvMSS      uint32
+	SendSSThresh uint32
+}
+
+//readTCPInfo reads TCP_INFO of the tcp connection if TCPConfig.Info is enabled,
+//errors are ignored since the metrics are informative only
+func (c *Client) readTCPInfo(label string) {
+	if !c.cfg.TCPConfig.Info || c.tcpConn == nil {
+		return
+	}
+	info, err := tcpInfo(c.tcpConn)
+	if err != nil {
+		return
+	}
+	info.Label = label
+	c.tcpInfos = append(c.tcpInfos, info)
+}
+
+//TCPInfos are the TCP_INFO read after connected and before disconnecting, it
+//is empty if TCPConfig.Info is disabled or the platform does not support it
+func (c *Client) TCPInfos() []*TCPInfo {
+	return c.tcpInfos
+}
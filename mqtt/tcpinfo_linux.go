@@ -0,0 +1,38 @@
+package mqtt
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func tcpInfo(conn *net.TCPConn) (*TCPInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var ti *unix.TCPInfo
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		ti, serr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+
+	return &TCPInfo{
+		Time:         time.Now(),
+		RTT:          time.Duration(ti.Rtt) * time.Microsecond,
+		RTTVar:       time.Duration(ti.Rttvar) * time.Microsecond,
+		Retransmits:  ti.Total_retrans,
+		Lost:         ti.Lost,
+		SendCwnd:     ti.Snd_cwnd,
+		SendMSS:      ti.Snd_mss,
+		RecvMSS:      ti.Rcv_mss,
+		SendSSThresh: ti.Snd_ssthresh,
+	}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package mqtt
+
+import (
+	"errors"
+	"net"
+)
+
+func tcpInfo(conn *net.TCPConn) (*TCPInfo, error) {
+	return nil, errors.New("TCP_INFO is supported on linux only")
+}
@@ -1,22 +1,30 @@
 package mqtt
 
 import (
+	"sync"
 	"time"
 )
 
 const (
-	TraceDNSLookup = "DNSLookup"
-	TraceTCPDial   = "TCPDial"
-	TraceTLSDial   = "TLSDial"
-	TraceConnect   = "Connect"
-	TraceConnack   = "Connack"
-	TraceSubscribe = "Subscribe"
-	TraceSuback    = "Suback"
-	TracePublish   = "Publish"
-	TracePuback    = "Puback"
-	TraceMessage   = "Message"
-	TracePing      = "Ping"
-	TracePong      = "Pong"
+	TraceDNSLookup    = "DNSLookup"
+	TraceDNSQueryA    = "DNSQueryA"
+	TraceDNSQueryAAAA = "DNSQueryAAAA"
+	TraceProxyDial    = "ProxyDial"
+	TraceProxyTunnel  = "ProxyTunnel"
+	TraceTCPDial      = "TCPDial"
+	TraceUnixDial     = "UnixDial"
+	TraceTLSDial      = "TLSDial"
+	TraceWebSocket    = "WebSocket"
+	TraceConnect      = "Connect"
+	TraceConnack      = "Connack"
+	TraceAuth         = "Auth" //followed by the number of the AUTH sent
+	TraceSubscribe    = "Subscribe"
+	TraceSuback       = "Suback"
+	TracePublish      = "Publish"
+	TracePuback       = "Puback"
+	TraceMessage      = "Message"
+	TracePing         = "Ping"
+	TracePong         = "Pong"
 )
 
 type Tracer interface {
@@ -30,15 +38,20 @@ type TracePoint struct {
 }
 
 type tracer struct {
+	mu     sync.Mutex
 	points []*TracePoint
 }
 
 func (t *tracer) AddPoint(key string, tm time.Time) {
+	t.mu.Lock()
 	t.points = append(t.points, &TracePoint{Key: key, Time: tm})
+	t.mu.Unlock()
 }
 
 func (t *tracer) Points() []*TracePoint {
-	return t.points
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.points[:len(t.points):len(t.points)]
 }
 
 func DefaultTracer() Tracer {
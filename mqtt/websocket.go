@@ -0,0 +1,70 @@
+package mqtt
+
+import (
+	"errors"
+	"net"
+	neturl "net/url"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	WSScheme  = "ws://"
+	WSSScheme = "wss://"
+)
+
+//parseWebSocketURL returns the host and port of a websocket url, the port is
+//80 or 443 by default
+func parseWebSocketURL(url string) (scheme, host, port string, err error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", "", "", stripURL(err)
+	}
+	if u.Host == "" {
+		return "", "", "", errors.New("host of websocket url is missing")
+	}
+	scheme = u.Scheme + "://"
+	host, port = u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == WSSScheme {
+			port = "443"
+		}
+	}
+	return scheme, host, port, nil
+}
+
+//wsConn keeps the addresses of the underlying connection, which the
+//websocket connection reports as urls
+type wsConn struct {
+	*websocket.Conn
+	conn net.Conn
+}
+
+func (w *wsConn) LocalAddr() net.Addr {
+	return w.conn.LocalAddr()
+}
+
+func (w *wsConn) RemoteAddr() net.Addr {
+	return w.conn.RemoteAddr()
+}
+
+//upgradeWebSocket makes the websocket handshake of url on conn with the mqtt
+//subprotocol, the packets are sent in binary frames
+func upgradeWebSocket(conn net.Conn, url string) (net.Conn, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, stripURL(err)
+	}
+	config, err := websocket.NewConfig(url, "http://"+u.Host)
+	if err != nil {
+		return nil, stripURL(err)
+	}
+	config.Protocol = []string{"mqtt"}
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return &wsConn{Conn: ws, conn: conn}, nil
+}
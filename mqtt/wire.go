@@ -0,0 +1,198 @@
+package mqtt
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+//Levels of the wire accounting
+const (
+	WireTCP  = "TCP"
+	WireTLS  = "TLS"
+	WireMQTT = "MQTT"
+)
+
+//WireEvent is bytes sent or received at a level, a TCP event is a read or
+//write of the socket, a TLS event is a record and a MQTT event is a packet
+type WireEvent struct {
+	Time   time.Time
+	Level  string
+	Sent   bool
+	Bytes  int
+	Packet string //type of the TLS record or MQTT packet
+}
+
+type wireEvents struct {
+	mu     sync.Mutex
+	events []*WireEvent
+}
+
+func (w *wireEvents) add(e *WireEvent) {
+	w.mu.Lock()
+	w.events = append(w.events, e)
+	w.mu.Unlock()
+}
+
+func (w *wireEvents) list() []*WireEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.events[:len(w.events):len(w.events)]
+}
+
+//framer splits a byte stream into frames
+type framer interface {
+	//feed consumes b and calls emit with the type and size of every completed frame
+	feed(b []byte, emit func(name string, size int))
+}
+
+//tlsFramer splits TLS records, the header of a record is type(1), version(2) and length(2)
+type tlsFramer struct {
+	header []byte
+	left   int //bytes left of the current record body
+	size   int
+	typ    byte
+}
+
+var tlsRecordTypes = map[byte]string{
+	20: "ChangeCipherSpec",
+	21: "Alert",
+	22: "Handshake",
+	23: "ApplicationData",
+}
+
+func (f *tlsFramer) feed(b []byte, emit func(name string, size int)) {
+	for len(b) > 0 {
+		if f.left == 0 {
+			f.header = append(f.header, b[0])
+			b = b[1:]
+			if len(f.header) < 5 {
+				continue
+			}
+			f.typ = f.header[0]
+			f.left = int(f.header[3])<<8 | int(f.header[4])
+			f.size = 5 + f.left
+			f.header = f.header[:0]
+		} else {
+			n := f.left
+			if n > len(b) {
+				n = len(b)
+			}
+			f.left -= n
+			b = b[n:]
+		}
+
+		if f.left == 0 {
+			name, found := tlsRecordTypes[f.typ]
+			if !found {
+				name = "Unknown"
+			}
+			emit(name, f.size)
+		}
+	}
+}
+
+//mqttFramer splits MQTT packets, the fixed header is type(1) and the remaining
+//length encoded in 1 to 4 bytes
+type mqttFramer struct {
+	header []byte
+	left   int
+	size   int
+}
+
+func (f *mqttFramer) feed(b []byte, emit func(name string, size int)) {
+	for len(b) > 0 {
+		if f.left == 0 {
+			f.header = append(f.header, b[0])
+			b = b[1:]
+			//the remaining length continues while the highest bit is set
+			if len(f.header) == 1 || f.header[len(f.header)-1]&0x80 != 0 && len(f.header) < 5 {
+				continue
+			}
+			mul := 1
+			for _, v := range f.header[1:] {
+				f.left += int(v&0x7F) * mul
+				mul *= 128
+			}
+			f.size = len(f.header) + f.left
+		} else {
+			n := f.left
+			if n > len(b) {
+				n = len(b)
+			}
+			f.left -= n
+			b = b[n:]
+		}
+
+		if f.left == 0 {
+			name := packets.PacketNames[f.header[0]>>4]
+			if f.header[0]>>4 == authPacket {
+				name = "AUTH" //MQTT 5 only
+			}
+			emit(name, f.size)
+			f.header = f.header[:0]
+		}
+	}
+}
+
+//wireConn records the bytes read and written at a level
+type wireConn struct {
+	net.Conn
+	level  string
+	events *wireEvents
+
+	//frames of each direction, nil if the level is not framed
+	in, out framer
+}
+
+func newWireConn(conn net.Conn, level string, events *wireEvents) *wireConn {
+	return &wireConn{Conn: conn, level: level, events: events}
+}
+
+//frame splits the stream of both directions by new
+func (c *wireConn) frame(new func() framer) *wireConn {
+	c.in, c.out = new(), new()
+	return c
+}
+
+func (c *wireConn) record(b []byte, sent bool) {
+	now := time.Now()
+	f := c.in
+	if sent {
+		f = c.out
+	}
+	if f == nil {
+		c.events.add(&WireEvent{Time: now, Level: c.level, Sent: sent, Bytes: len(b)})
+		return
+	}
+	f.feed(b, func(name string, size int) {
+		c.events.add(&WireEvent{Time: now, Level: c.level, Sent: sent, Bytes: size, Packet: name})
+	})
+}
+
+func (c *wireConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.record(b[:n], false)
+	}
+	return n, err
+}
+
+func (c *wireConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.record(b[:n], true)
+	}
+	return n, err
+}
+
+//WireEvents are the bytes sent and received by the client, it is empty if
+//WireAccounting of the config is disabled
+func (c *Client) WireEvents() []*WireEvent {
+	if c.wire == nil {
+		return nil
+	}
+	return c.wire.list()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/mqtt"
+	"github.com/shafreeck/mqttstat/report"
+)
+
+//runOpenLoop runs count rounds at the intended times of s and adds them to rs,
+//rounds may overlap so every round connects with its own client id. No more
+//rounds are issued after stop is closed, the finished ones are reported to out
+func runOpenLoop(cfg *mqtt.ClientConfig, address string, args []string, count int, s *bench.Scheduler, rs *rounds, stop <-chan struct{}, out io.Writer) {
+	latency := bench.NewHistogram()
+	service := bench.NewHistogram()
+	phases := make(map[string]*bench.Histogram)
+	var names []string
+	var done, failed int
+
+	//every round writes its own slot, report reads it after the round finished
+	stats := make([]*report.Stat, count)
+
+	begin := time.Now()
+	s.Run(count, func(seq int) error {
+		c := mqtt.NewClient(cfg)
+		c.SetClientID(cfg.ClientID + "-" + strconv.Itoa(seq))
+		c.SetRound(seq + 1)
+		if err := runRound(c, address, args); err != nil {
+			return err
+		}
+		stat, err := report.FromClient(c, address, phasesOf(args))
+		if err != nil {
+			return err
+		}
+		stats[seq] = stat
+		return nil
+	}, func(r *bench.Result) {
+		done++
+		if r.Err != nil {
+			failed++
+			rs.fail()
+			fmt.Fprintln(os.Stderr, color(RedFmt, r.Err))
+			return
+		}
+		latency.Record(r.Latency())
+		service.Record(r.ServiceTime())
+		if err := rs.add(stats[r.Seq], r.Latency()); err != nil {
+			log.Fatalln(err)
+		}
+
+		for _, field := range stats[r.Seq].Fields {
+			h, found := phases[field.Name]
+			if !found {
+				h = bench.NewHistogram()
+				phases[field.Name] = h
+				names = append(names, field.Name)
+			}
+			h.Record(field.Cost)
+		}
+		stats[r.Seq] = nil
+	}, stop)
+	elapsed := time.Since(begin)
+
+	fmt.Fprintln(out, "Open loop to", color(GreenFmt, address), "at", color(GreenFmt, s.Rate), "rounds/s with", s.Arrival, "arrival")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, color(GreyFmt, "Rounds"), ":", color(GreenFmt, done))
+	if done < count {
+		fmt.Fprintln(out, color(GreyFmt, "Stopped"), ":", color(RedFmt, fmt.Sprint(count-done, " rounds not issued")))
+	}
+	if failed > 0 {
+		fmt.Fprintln(out, color(GreyFmt, "Failed"), ":", color(RedFmt, failed))
+	}
+	fmt.Fprintln(out, color(GreyFmt, "Throughput"), ":", color(GreenFmt, fmt.Sprintf("%.2f rounds/s", float64(done-failed)/elapsed.Seconds())))
+	fmt.Fprintln(out)
+
+	if latency.Count() == 0 {
+		return
+	}
+	bench.PrintHeader(out)
+	bench.PrintRow(out, "Latency", latency)
+	bench.PrintRow(out, "Service Time", service)
+	for _, name := range names {
+		bench.PrintRow(out, name, phases[name])
+	}
+}
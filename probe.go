@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/mqtt"
+	"github.com/shafreeck/mqttstat/report"
+)
+
+//ipProbe is the costs of the rounds connected to an ip
+type ipProbe struct {
+	ip     string
+	phases map[string]*bench.Histogram
+	failed int
+	err    error
+}
+
+//probeIPs resolves the host of address and runs count rounds against every
+//one of the addresses, in turn or concurrently
+func probeIPs(cfg *mqtt.ClientConfig, address string, args []string, count int, delay time.Duration, concurrent bool) error {
+	_, host, _, err := mqtt.ParseURL(address)
+	if err != nil {
+		return err
+	}
+	var ips []string
+	if cfg.Resolver != nil {
+		res, err := cfg.Resolver.Lookup(host, nil)
+		if err != nil {
+			return err
+		}
+		ips = res.Addrs
+	} else if ips, err = net.LookupHost(host); err != nil {
+		return err
+	}
+	//the addresses of the other family of -4 or -6 are not probed
+	if ips, err = mqtt.FilterFamily(cfg.Network, host, ips); err != nil {
+		return err
+	}
+
+	probes := make([]*ipProbe, len(ips))
+	var names []string
+	var mu sync.Mutex //protects names
+	run := func(i int) {
+		p := &ipProbe{ip: ips[i], phases: make(map[string]*bench.Histogram)}
+		probes[i] = p
+		for round := 0; round < count; round++ {
+			c := mqtt.NewClient(cfg)
+			c.SetPinIP(ips[i])
+			c.SetRound(round + 1)
+			if concurrent {
+				c.SetClientID(cfg.ClientID + "-" + strconv.Itoa(i))
+			}
+			if err := runRound(c, address, args); err != nil {
+				p.failed++
+				p.err = err
+				continue
+			}
+
+			stat, err := report.Parse(c.TracePoints(), phasesOf(args))
+			if err != nil {
+				p.failed++
+				p.err = err
+				continue
+			}
+			mu.Lock()
+			for _, field := range stat.Fields {
+				h, found := p.phases[field.Name]
+				if !found {
+					h = bench.NewHistogram()
+					p.phases[field.Name] = h
+				}
+				h.Record(field.Cost)
+				if !contains(names, field.Name) {
+					names = append(names, field.Name)
+				}
+			}
+			mu.Unlock()
+
+			h, found := p.phases[report.TotalField]
+			if !found {
+				h = bench.NewHistogram()
+				p.phases[report.TotalField] = h
+			}
+			h.Record(stat.Total())
+
+			if round < count-1 {
+				time.Sleep(delay)
+			}
+		}
+	}
+
+	if concurrent {
+		var wg sync.WaitGroup
+		for i := range ips {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range ips {
+			run(i)
+		}
+	}
+
+	fmt.Println("Probed", len(ips), "addresses of", color(GreenFmt, host), "with", count, "rounds each")
+	fmt.Println()
+	displayProbes(os.Stdout, probes, append(names, report.TotalField))
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+//displayProbes prints the median cost of every phase by ip
+func displayProbes(out io.Writer, probes []*ipProbe, names []string) {
+	width := 15
+	for _, p := range probes {
+		if len(p.ip) > width {
+			width = len(p.ip)
+		}
+	}
+
+	fmt.Fprintf(out, "%-*v  %6v", width, "Address (p50)", "failed")
+	for _, name := range names {
+		fmt.Fprint(out, "  ", color(GreyFmt, fmt.Sprintf("%*v", len(name), name)))
+	}
+	fmt.Fprintln(out)
+
+	for _, p := range probes {
+		fmt.Fprintf(out, "%-*v  %6v", width, p.ip, p.failed)
+		for _, name := range names {
+			h, found := p.phases[name]
+			if !found {
+				fmt.Fprintf(out, "  %*v", len(name), "-")
+				continue
+			}
+			fmt.Fprint(out, "  ", color(GreenFmt, fmt.Sprintf("%*v", len(name), h.Percentile(50))))
+		}
+		fmt.Fprintln(out)
+	}
+
+	for _, p := range probes {
+		if p.err != nil {
+			fmt.Fprintln(out, color(RedFmt, p.ip+": "+p.err.Error()))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type jsonPhase struct {
+	Name    string            `json:"name"`
+	Cost    int64             `json:"cost_ns"`
+	Bytes   map[string]*Bytes `json:"bytes,omitempty"`
+	Packets []*Packet         `json:"packets,omitempty"`
+}
+
+type jsonStat struct {
+	Server   string            `json:"server"`
+	Local    string            `json:"local"`
+	ClientID string            `json:"client_id"`
+	Begin    time.Time         `json:"begin"`
+	Total    int64             `json:"total_ns"`
+	Phases   []*jsonPhase      `json:"phases"`
+	Bytes    map[string]*Bytes `json:"bytes,omitempty"`
+}
+
+//JSON renders the stat as a line of json
+func JSON(out io.Writer, stat *Stat) error {
+	s := &jsonStat{
+		Server:   stat.Server,
+		Local:    stat.Local,
+		ClientID: stat.ClientID,
+		Begin:    stat.Begin,
+		Total:    int64(stat.Total()),
+		Bytes:    stat.Bytes,
+	}
+	for _, field := range stat.Fields {
+		s.Phases = append(s.Phases, &jsonPhase{Name: field.Name, Cost: int64(field.Cost), Bytes: field.Bytes, Packets: field.Packets})
+	}
+	return json.NewEncoder(out).Encode(s)
+}
+
+//ReadJSON reads the stats written by JSON, one per line
+func ReadJSON(in io.Reader) ([]*Stat, error) {
+	var stats []*Stat
+	dec := json.NewDecoder(in)
+	for {
+		var s jsonStat
+		if err := dec.Decode(&s); err == io.EOF {
+			return stats, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		stat := &Stat{
+			Server:   s.Server,
+			Local:    s.Local,
+			ClientID: s.ClientID,
+			Begin:    s.Begin,
+			End:      s.Begin.Add(time.Duration(s.Total)),
+			Bytes:    s.Bytes,
+		}
+		t := s.Begin
+		for _, p := range s.Phases {
+			stat.Fields = append(stat.Fields, &Field{Name: p.Name, Cost: time.Duration(p.Cost), Time: t, Bytes: p.Bytes, Packets: p.Packets})
+			t = t.Add(time.Duration(p.Cost))
+		}
+		stats = append(stats, stat)
+	}
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	begin := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	var stats []*Stat
+	for i := 0; i < 2; i++ {
+		stat := &Stat{Server: "tcp://127.0.0.1:1883", Local: "127.0.0.1:40000", ClientID: "mqttstat", Begin: begin}
+		t := begin
+		for j, name := range []string{TCPConnectionField, MQTTConnectionField, "MQTT Publish"} {
+			cost := time.Duration(i*1000+j+1) * time.Microsecond
+			stat.Fields = append(stat.Fields, &Field{Name: name, Cost: cost, Time: t})
+			t = t.Add(cost)
+		}
+		stat.End = t
+		stats = append(stats, stat)
+	}
+	stats[1].Bytes = map[string]*Bytes{mqtt.WireTCP: {Sent: 53, Received: 9}, mqtt.WireMQTT: {Sent: 53, Received: 9}}
+	stats[1].Fields[1].Bytes = map[string]*Bytes{mqtt.WireTCP: {Sent: 22, Received: 4}, mqtt.WireMQTT: {Sent: 22, Received: 4}}
+	stats[1].Fields[1].Packets = []*Packet{{Name: "CONNECT", Size: 22, Sent: true}, {Name: "CONNACK", Size: 4}}
+
+	var buf bytes.Buffer
+	for _, stat := range stats {
+		if err := JSON(&buf, stat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(stats) {
+		t.Fatalf("got %v stats, want %v", len(got), len(stats))
+	}
+	for i, want := range stats {
+		g := got[i]
+		if g.Server != want.Server || g.Local != want.Local || g.ClientID != want.ClientID {
+			t.Errorf("stat %v: got %v %v %v", i, g.Server, g.Local, g.ClientID)
+		}
+		if !g.Begin.Equal(want.Begin) || !g.End.Equal(want.End) {
+			t.Errorf("stat %v: got %v - %v, want %v - %v", i, g.Begin, g.End, want.Begin, want.End)
+		}
+		if !reflect.DeepEqual(g.Bytes, want.Bytes) {
+			t.Errorf("stat %v: got bytes %v, want %v", i, g.Bytes, want.Bytes)
+		}
+		if len(g.Fields) != len(want.Fields) {
+			t.Fatalf("stat %v: got %v fields, want %v", i, len(g.Fields), len(want.Fields))
+		}
+		for j, f := range want.Fields {
+			gf := g.Fields[j]
+			if gf.Name != f.Name || gf.Cost != f.Cost || !gf.Time.Equal(f.Time) ||
+				!reflect.DeepEqual(gf.Bytes, f.Bytes) || !reflect.DeepEqual(gf.Packets, f.Packets) {
+				t.Errorf("stat %v field %v: got %+v, want %+v", i, j, gf, f)
+			}
+		}
+	}
+}
+
+func TestReadJSONInvalid(t *testing.T) {
+	if _, err := ReadJSON(bytes.NewBufferString("{\"server\":")); err == nil {
+		t.Error("truncated json should fail")
+	}
+}
@@ -0,0 +1,212 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	greenFmt = "\033[32m%v\033[0m"
+	redFmt   = "\033[31m%v\033[0m"
+	greyFmt  = "\033[0;37m%v\033[0m"
+)
+
+//Color tells whether the renderers color their output, on if stdout is a terminal
+var Color = isatty.IsTerminal(os.Stdout.Fd())
+
+//Renderer writes a stat to out
+type Renderer interface {
+	Render(out io.Writer, stat *Stat) error
+}
+
+//RendererFunc adapts a func to Renderer
+type RendererFunc func(out io.Writer, stat *Stat) error
+
+func (f RendererFunc) Render(out io.Writer, stat *Stat) error {
+	return f(out, stat)
+}
+
+var renderers = make(map[string]Renderer)
+
+//Register makes r available by name, it panics if name is registered twice
+func Register(name string, r Renderer) {
+	if _, found := renderers[name]; found {
+		panic("report: renderer " + name + " registered twice")
+	}
+	renderers[name] = r
+}
+
+//Lookup returns the renderer registered by name
+func Lookup(name string) (Renderer, bool) {
+	r, found := renderers[name]
+	return r, found
+}
+
+//Names returns the names of the registered renderers in order
+func Names() []string {
+	var names []string
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("timeline", RendererFunc(Timeline))
+	Register("bars", RendererFunc(Bars))
+	Register("bytes", RendererFunc(BytesTable))
+	Register("json", RendererFunc(JSON))
+}
+
+func color(colorfmt string, v interface{}) string {
+	if Color {
+		return fmt.Sprintf(colorfmt, v)
+	}
+	return fmt.Sprint(v)
+}
+
+func feedSpace(w io.Writer, count int) {
+	for i := 0; i < count; i++ {
+		w.Write([]byte{' '})
+	}
+}
+
+func splitSpaceCount(count int) (int, int) {
+	if count%2 == 0 {
+		c := count / 2
+		return c, c
+	}
+	c := count / 2
+	return c, c + 1
+}
+
+//Timeline renders the cost of every phase and the time elapsed at the end of it
+func Timeline(out io.Writer, stat *Stat) error {
+	lines := make([]bytes.Buffer, len(stat.Fields)+3) // add extra 3 lines: field, time distribution, and total cost
+	start := stat.Begin
+	total := ""
+	offset := 0
+	position := 0
+	for i, field := range stat.Fields {
+		begin, end := "|", ""
+		if i == 0 {
+			begin = "["
+		}
+		if i == len(stat.Fields)-1 {
+			end = "]"
+		}
+		width := len(field.Name) + 3
+
+		//header line
+		feedSpace(&lines[0], 2)
+		lines[0].WriteString(color(greyFmt, field.Name))
+		feedSpace(&lines[0], 1)
+
+		//cost line
+		spaceCount := width - len(begin) - len([]rune(fmt.Sprint(field.Cost)))
+		if spaceCount < 0 {
+			feedSpace(&lines[0], -spaceCount)
+			spaceCount = 0
+		}
+
+		pre, post := splitSpaceCount(spaceCount)
+
+		lines[1].WriteString(begin)
+		feedSpace(&lines[1], pre)
+		lines[1].WriteString(color(greenFmt, field.Cost))
+		feedSpace(&lines[1], post)
+		lines[1].WriteString(end)
+
+		offset += width
+		//total cost lines
+		for j := 0; j <= i; j++ {
+			if j == i {
+				feedSpace(&lines[2+j], offset-position-len([]rune(total)))
+				lines[2+j].WriteString("|")
+
+				if i == len(stat.Fields)-1 {
+					total = fmt.Sprint(stat.End.Sub(start))
+				} else {
+					total = fmt.Sprint(stat.Fields[i+1].Time.Sub(start))
+				}
+
+				position = offset - len([]rune(total))/2
+				feedSpace(&lines[3+j], position)
+				lines[3+j].WriteString(color(greenFmt, total))
+			} else {
+				feedSpace(&lines[2+j], width-1)
+				lines[2+j].WriteString("|")
+			}
+		}
+	}
+
+	for _, line := range lines {
+		fmt.Fprintln(out, line.String())
+	}
+	return nil
+}
+
+//Bars renders the share of every phase in the total cost as a bar, supplied by "li ziang"
+func Bars(out io.Writer, stat *Stat) error {
+	var totalCost, min time.Duration
+	min = stat.Fields[0].Cost
+	for _, field := range stat.Fields {
+		if min > field.Cost {
+			min = field.Cost
+		}
+		totalCost += field.Cost
+	}
+
+	for _, field := range stat.Fields {
+		fmt.Fprintf(out, "%-21v  %15v\t", field.Name, field.Cost)
+		count := int(float64(field.Cost) * 100 / float64(totalCost))
+		line := ""
+		for i := 0; i < count; i++ {
+			line += "█"
+		}
+		if count > 100/len(stat.Fields) {
+			fmt.Fprintln(out, color(redFmt, line))
+		} else {
+			fmt.Fprintln(out, color(greenFmt, line))
+		}
+	}
+	return nil
+}
+
+//Vertical renders the phases from top to bottom, as tall as their cost
+func Vertical(out io.Writer, stat *Stat) error {
+	var totalCost, min, sofar time.Duration
+	min = stat.Fields[0].Cost
+	for _, field := range stat.Fields {
+		if min > field.Cost {
+			min = field.Cost
+		}
+		totalCost += field.Cost
+	}
+
+	unit := 1
+
+	fmt.Fprintln(out, " --")
+	for _, field := range stat.Fields {
+		lineCount := int(field.Cost/min) * unit
+		sofar += field.Cost
+
+		for i := 0; i < lineCount; i++ {
+			if i == lineCount/2 {
+				fmt.Fprintf(out, " |    %s (%v)\n", field.Name, color(greenFmt, field.Cost))
+			} else {
+				fmt.Fprintln(out, " | ")
+			}
+		}
+		fmt.Fprintf(out, " |<--  %v\n", color(greenFmt, sofar))
+	}
+	fmt.Fprintln(out, " --")
+	return nil
+}
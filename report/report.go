@@ -0,0 +1,166 @@
+//Package report turns the trace points of a round into the cost of every phase
+//and renders it, so the measurements can be embedded in other programs
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+const (
+	DNSLookupField       = "DNS Lookup"
+	DNSQueryAField       = "DNS Query A"
+	DNSQueryAAAAField    = "DNS Query AAAA"
+	ProxyConnectionField = "Proxy Connection"
+	ProxyTunnelField     = "Proxy Tunnel"
+	TCPConnectionField   = "TCP Connection"
+	UnixConnectionField  = "Unix Connection"
+	TLSHandshakeField    = "TLS Handshake"
+	WebSocketField       = "WebSocket Upgrade"
+	MQTTConnectionField  = "MQTT Connection"
+	MQTTAuthField        = "MQTT Auth"
+	TotalField           = "Total"
+)
+
+//ErrNotConnected is returned for the trace points of a round that never got a CONNACK
+var ErrNotConnected = errors.New("report: no CONNACK in the trace points")
+
+//Phase is measured from the trace point Begin to End in a round, it begins
+//where the previous phase ended if Begin is empty
+type Phase struct {
+	Name  string
+	Begin string
+	End   string
+}
+
+//Field is the cost of a phase
+type Field struct {
+	Name string
+	Cost time.Duration
+	Time time.Time //when the phase began
+
+	Bytes   map[string]*Bytes //by level, nil if wire accounting is disabled
+	Packets []*Packet
+}
+
+//Stat is the report of a round
+type Stat struct {
+	Server   string
+	Local    string
+	ClientID string
+
+	Begin  time.Time
+	End    time.Time
+	Fields []*Field
+	Bytes  map[string]*Bytes //by level, nil if wire accounting is disabled
+}
+
+//Total is the cost of the whole round
+func (stat *Stat) Total() time.Duration {
+	return stat.End.Sub(stat.Begin)
+}
+
+//Field returns the field of name, nil if the round has no such phase
+func (stat *Stat) Field(name string) *Field {
+	for _, f := range stat.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+//FromClient reports the last round of c, which connected to server
+func FromClient(c *mqtt.Client, server string, phases []Phase) (*Stat, error) {
+	stat, err := Parse(c.TracePoints(), phases)
+	if err != nil {
+		return nil, err
+	}
+	stat.Server = server
+	stat.Local = fmt.Sprint(c.LocalAddr())
+	stat.ClientID = c.ClientID()
+	stat.AddWire(c.WireEvents())
+	return stat, nil
+}
+
+//Parse breaks points into the connection phases and phases
+func Parse(points []*mqtt.TracePoint, phases []Phase) (*Stat, error) {
+	ts := make(map[string]time.Time)
+	for _, p := range points {
+		ts[p.Key] = p.Time
+	}
+	if _, found := ts[mqtt.TraceConnack]; !found {
+		return nil, ErrNotConnected
+	}
+
+	stat := &Stat{Begin: points[0].Time, End: points[len(points)-1].Time}
+	var last time.Time
+	var f *Field
+	next := func(name string, t time.Time) {
+		if f != nil {
+			f.Cost = t.Sub(last)
+		}
+		f = &Field{Name: name, Time: t}
+		stat.Fields = append(stat.Fields, f)
+		last = t
+	}
+
+	connection := []struct{ key, name string }{
+		{mqtt.TraceDNSLookup, DNSLookupField},
+		{mqtt.TraceDNSQueryA, DNSQueryAField},
+		{mqtt.TraceDNSQueryAAAA, DNSQueryAAAAField},
+		{mqtt.TraceProxyDial, ProxyConnectionField},
+		{mqtt.TraceProxyTunnel, ProxyTunnelField},
+		{mqtt.TraceTCPDial, TCPConnectionField},
+		{mqtt.TraceUnixDial, UnixConnectionField},
+		{mqtt.TraceTLSDial, TLSHandshakeField},
+		{mqtt.TraceWebSocket, WebSocketField},
+		{mqtt.TraceConnect, MQTTConnectionField},
+	}
+	for _, c := range connection {
+		if t, found := ts[c.key]; found {
+			next(c.name, t)
+		}
+	}
+
+	//every AUTH sent starts a round-trip of the enhanced authentication
+	for n := 1; ; n++ {
+		t, found := ts[mqtt.TraceAuth+strconv.Itoa(n)]
+		if !found {
+			break
+		}
+		next(MQTTAuthField+" "+strconv.Itoa(n), t)
+	}
+
+	if f == nil {
+		return nil, ErrNotConnected
+	}
+	t := ts[mqtt.TraceConnack]
+	f.Cost = t.Sub(last)
+	last = t
+
+	for _, p := range phases {
+		begin, found := last, true
+		if p.Begin != "" {
+			begin, found = ts[p.Begin]
+		} else {
+			_, found = ts[p.End]
+		}
+		if !found {
+			continue
+		}
+
+		f = &Field{Name: p.Name, Time: begin}
+		stat.Fields = append(stat.Fields, f)
+		last = begin
+		if t, found := ts[p.End]; found {
+			f.Cost = t.Sub(last)
+			last = t
+		}
+	}
+	return stat, nil
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+var wireLevels = []string{mqtt.WireTCP, mqtt.WireTLS, mqtt.WireMQTT}
+
+//Bytes sent and received at a level
+type Bytes struct {
+	Sent     int `json:"sent"`
+	Received int `json:"received"`
+}
+
+//Packet is a MQTT packet sent or received
+type Packet struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+	Sent bool   `json:"sent"`
+}
+
+func (b *Bytes) add(e *mqtt.WireEvent) {
+	if e.Sent {
+		b.Sent += e.Bytes
+	} else {
+		b.Received += e.Bytes
+	}
+}
+
+//AddWire attributes the wire events to the phases they happened in, the
+//events out of all of the phases are counted in the total only
+func (stat *Stat) AddWire(events []*mqtt.WireEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	stat.Bytes = make(map[string]*Bytes)
+	for _, level := range wireLevels {
+		stat.Bytes[level] = &Bytes{}
+	}
+	for _, field := range stat.Fields {
+		field.Bytes = make(map[string]*Bytes)
+		for _, level := range wireLevels {
+			field.Bytes[level] = &Bytes{}
+		}
+	}
+
+	for _, e := range events {
+		stat.Bytes[e.Level].add(e)
+		for i, field := range stat.Fields {
+			end := stat.End
+			if i < len(stat.Fields)-1 {
+				end = stat.Fields[i+1].Time
+			}
+			if e.Time.Before(field.Time) || !e.Time.Before(end) {
+				continue
+			}
+			field.Bytes[e.Level].add(e)
+			if e.Level == mqtt.WireMQTT {
+				field.Packets = append(field.Packets, &Packet{Name: e.Packet, Size: e.Bytes, Sent: e.Sent})
+			}
+			break
+		}
+	}
+}
+
+//BytesTable renders the bytes and the MQTT packets of every phase, nothing if
+//wire accounting is disabled
+func BytesTable(out io.Writer, stat *Stat) error {
+	if stat.Bytes == nil {
+		return nil
+	}
+
+	var levels []string
+	for _, level := range wireLevels {
+		if b := stat.Bytes[level]; b.Sent > 0 || b.Received > 0 {
+			levels = append(levels, level)
+		}
+	}
+
+	fmt.Fprintf(out, "%-21v", "Bytes (sent/received)")
+	for _, level := range levels {
+		fmt.Fprintf(out, "  %13v", level)
+	}
+	fmt.Fprintln(out, "  MQTT Packets")
+
+	row := func(name string, bytes map[string]*Bytes, packets []*Packet) {
+		fmt.Fprintf(out, "%-21v", name)
+		for _, level := range levels {
+			b := bytes[level]
+			fmt.Fprintf(out, "  %13v", fmt.Sprintf("%v/%v", b.Sent, b.Received))
+		}
+		var ps []string
+		for _, p := range packets {
+			dir := "<"
+			if p.Sent {
+				dir = ">"
+			}
+			ps = append(ps, fmt.Sprintf("%v%v(%v)", dir, p.Name, p.Size))
+		}
+		fmt.Fprintln(out, " ", strings.Join(ps, " "))
+	}
+	for _, field := range stat.Fields {
+		row(field.Name, field.Bytes, field.Packets)
+	}
+	row(TotalField, stat.Bytes, nil)
+	return nil
+}
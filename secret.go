@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+//redacted replaces a secret in the output, it does not tell the length
+const redacted = "******"
+
+//secretFlags are the flags never shown by their values
+var secretFlags = []string{"password", "auth.sharedkey"}
+
+//readPassword reads the password from file, or prompts for it on the
+//terminal without echo
+func readPassword(file string, prompt bool) (string, error) {
+	if file != "" && prompt {
+		return "", errors.New("-password.file and -password.prompt can not be used together")
+	}
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("can not prompt for password, stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(data), err
+}
+
+//warnReadable warns if the config file holding secrets can be read by others
+func warnReadable(path string, p profile) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0077 == 0 {
+		return
+	}
+	for _, name := range secretFlags {
+		if _, found := p[name]; found {
+			fmt.Fprintf(os.Stderr, "warning: %v holds %v but can be read by others, chmod 600 it\n", path, name)
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package subcmd
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+//dialClients connects n clients concurrently, the client id of each one is
+//suffixed by role and its index. setup is called before connecting if it is not nil
+func dialClients(cfg *mqtt.ClientConfig, address, role string, n int, timeout time.Duration, setup func(i int, c *mqtt.Client)) ([]*mqtt.Client, error) {
+	cs := make([]*mqtt.Client, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := range cs {
+		c := mqtt.NewClient(cfg)
+		c.SetClientID(cfg.ClientID + "-" + role + "-" + strconv.Itoa(i))
+		if setup != nil {
+			setup(i, c)
+		}
+		cs[i] = c
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = cs[i].Dial(address, net.Dialer{Timeout: timeout})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			for j := range cs {
+				if errs[j] == nil {
+					cs[j].Disconnect()
+				}
+			}
+			return nil, &clientError{ClientID: cs[i].ClientID(), Err: err}
+		}
+	}
+	return cs, nil
+}
+
+func disconnectClients(cs []*mqtt.Client) {
+	var wg sync.WaitGroup
+	for _, c := range cs {
+		wg.Add(1)
+		go func(c *mqtt.Client) {
+			defer wg.Done()
+			c.Disconnect()
+		}(c)
+	}
+	wg.Wait()
+}
+
+//subscribeClients subscribes topic of each client concurrently
+func subscribeClients(cs []*mqtt.Client, topic func(i int) string, qos int) error {
+	errs := make([]error, len(cs))
+	var wg sync.WaitGroup
+	for i, c := range cs {
+		wg.Add(1)
+		go func(i int, c *mqtt.Client) {
+			defer wg.Done()
+			errs[i] = c.Subscribe([]string{topic(i)}, []int{qos})
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return &clientError{ClientID: cs[i].ClientID(), Err: err}
+		}
+	}
+	return nil
+}
+
+type clientError struct {
+	ClientID string
+	Err      error
+}
+
+func (e *clientError) Error() string {
+	return e.ClientID + ": " + e.Err.Error()
+}
+
+func (e *clientError) Unwrap() error {
+	return e.Err
+}
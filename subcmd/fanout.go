@@ -0,0 +1,205 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+//fanFlags are the flags shared by fanout and fanin
+type fanFlags struct {
+	topic    string
+	clients  int
+	count    int
+	qos      int
+	size     int
+	distinct bool
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func (f *fanFlags) register(fs *flag.FlagSet, clients string) {
+	fs.StringVar(&f.topic, "topic", "/mqttstat", "topic to publish message to")
+	fs.IntVar(&f.clients, "clients", 1000, "count of "+clients)
+	fs.IntVar(&f.count, "count", 10, "count of messages to publish by each publisher")
+	fs.IntVar(&f.qos, "qos", 1, "qos of message")
+	fs.IntVar(&f.size, "size", probeLen, "size of message, at least "+strconv.Itoa(probeLen))
+	fs.BoolVar(&f.distinct, "distinct", false, "use a distinct topic for each of the "+clients)
+	fs.DurationVar(&f.interval, "interval", 100*time.Millisecond, "time to delay before publishing the next message")
+	fs.DurationVar(&f.timeout, "timeout", 10*time.Second, "time to wait for the messages and for connecting")
+}
+
+func (f *fanFlags) distinctTopic(i int) string {
+	return f.topic + "/" + strconv.Itoa(i)
+}
+
+//publishProbes publishes count probes of publisher to topics, the same seq
+//is published to every one of the topics
+func publishProbes(c *mqtt.Client, publisher int, topics []string, f *fanFlags) error {
+	for seq := 0; seq < f.count; seq++ {
+		for _, topic := range topics {
+			p := &probe{Publisher: uint32(publisher), Seq: uint32(seq), Sent: time.Now()}
+			ackc, err := c.Publish(topic, p.marshal(f.size), f.qos)
+			if err != nil {
+				return err
+			}
+			if err := waitAck(ackc, f.timeout); err != nil {
+				return err
+			}
+		}
+		if seq < f.count-1 {
+			time.Sleep(f.interval)
+		}
+	}
+	return nil
+}
+
+//waitAck waits for the ack of a publish and returns its error, ackc is nil for qos 0
+func waitAck(ackc chan mqtt.ACK, timeout time.Duration) error {
+	if ackc == nil {
+		return nil
+	}
+	select {
+	case ack := <-ackc:
+		return ack.Err
+	case <-time.After(timeout):
+		return errors.New("timeout waiting for the ack of publish")
+	}
+}
+
+func displayDeliveries(d *deliveries, begin time.Time) {
+	d.mu.Lock()
+	received, dups, end := d.received, d.dups, d.end
+	d.mu.Unlock()
+
+	fmt.Printf("%-12v: %v\n", "Delivered", received)
+	fmt.Printf("%-12v: %v\n", "Missing", d.expected-received)
+	fmt.Printf("%-12v: %v\n", "Duplicated", dups)
+	if received > 0 {
+		fmt.Printf("%-12v: %v\n", "Elapsed", end.Sub(begin))
+		fmt.Printf("%-12v: %.2f msgs/s\n", "Throughput", float64(received)/end.Sub(begin).Seconds())
+	}
+	fmt.Println()
+	if received == 0 {
+		return
+	}
+
+	bench.PrintHeader(os.Stdout)
+	bench.PrintRow(os.Stdout, "Delivery latency", d.latency)
+	bench.PrintRow(os.Stdout, "Last delivery", d.lastDelivery())
+}
+
+//FanoutCommand publishes messages from one client to lots of subscribers
+func FanoutCommand(cfg *mqtt.ClientConfig, address string, args []string) error {
+	var f fanFlags
+	fs := flag.NewFlagSet("fanout", flag.ExitOnError)
+	f.register(fs, "subscribers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	d := newDeliveries(f.clients * f.count)
+	subs, err := dialClients(cfg, address, "sub", f.clients, f.timeout, func(i int, c *mqtt.Client) {
+		c.SetRecvHandler(func(topic string, message []byte, qos int) error {
+			return d.add(i, message)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	defer disconnectClients(subs)
+
+	topics := []string{f.topic}
+	topic := func(i int) string { return f.topic }
+	if f.distinct {
+		topics = make([]string, f.clients)
+		for i := range topics {
+			topics[i] = f.distinctTopic(i)
+		}
+		topic = f.distinctTopic
+	}
+	if err := subscribeClients(subs, topic, f.qos); err != nil {
+		return err
+	}
+
+	pubs, err := dialClients(cfg, address, "pub", 1, f.timeout, nil)
+	if err != nil {
+		return err
+	}
+	defer disconnectClients(pubs)
+
+	fmt.Println("Fan-out of", f.count, "messages to", f.clients, "subscribers on", f.topic)
+	fmt.Println()
+
+	begin := time.Now()
+	if err := publishProbes(pubs[0], 0, topics, &f); err != nil {
+		return err
+	}
+	d.wait(f.timeout)
+	displayDeliveries(d, begin)
+	return nil
+}
+
+//FaninCommand publishes messages from lots of clients to one subscriber
+func FaninCommand(cfg *mqtt.ClientConfig, address string, args []string) error {
+	var f fanFlags
+	fs := flag.NewFlagSet("fanin", flag.ExitOnError)
+	f.register(fs, "publishers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	d := newDeliveries(f.clients * f.count)
+	subs, err := dialClients(cfg, address, "sub", 1, f.timeout, func(i int, c *mqtt.Client) {
+		c.SetRecvHandler(func(topic string, message []byte, qos int) error {
+			return d.add(i, message)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	defer disconnectClients(subs)
+
+	filter := f.topic
+	if f.distinct {
+		filter = f.topic + "/+"
+	}
+	if err := subs[0].Subscribe([]string{filter}, []int{f.qos}); err != nil {
+		return err
+	}
+
+	pubs, err := dialClients(cfg, address, "pub", f.clients, f.timeout, nil)
+	if err != nil {
+		return err
+	}
+	defer disconnectClients(pubs)
+
+	fmt.Println("Fan-in of", f.count, "messages from each of", f.clients, "publishers on", filter)
+	fmt.Println()
+
+	begin := time.Now()
+	errc := make(chan error, len(pubs))
+	for i, c := range pubs {
+		topic := f.topic
+		if f.distinct {
+			topic = f.distinctTopic(i)
+		}
+		go func(i int, c *mqtt.Client, topic string) {
+			errc <- publishProbes(c, i, []string{topic}, &f)
+		}(i, c, topic)
+	}
+	for range pubs {
+		if err := <-errc; err != nil {
+			return err
+		}
+	}
+	d.wait(f.timeout)
+	displayDeliveries(d, begin)
+	return nil
+}
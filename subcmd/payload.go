@@ -0,0 +1,186 @@
+package subcmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+//Payload generates the content of messages
+type Payload interface {
+	Next(clientID string) ([]byte, error)
+}
+
+//seq is shared by all of the payloads so that it keeps growing across rounds
+var seq int64
+
+type literalPayload []byte
+
+func (p literalPayload) Next(string) ([]byte, error) {
+	return p, nil
+}
+
+var stdin struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+//readStdin reads stdin once, every round publishes the same content
+func readStdin() ([]byte, error) {
+	stdin.once.Do(func() {
+		stdin.data, stdin.err = ioutil.ReadAll(os.Stdin)
+	})
+	return stdin.data, stdin.err
+}
+
+//randomPayload generates random bytes of size in [min, max]
+type randomPayload struct {
+	min, max int
+}
+
+func parseSizeRange(s string) (int, int, error) {
+	parts := strings.SplitN(s, "-", 2)
+	min, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	max := min
+	if len(parts) == 2 {
+		if max, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if min < 0 || max < min {
+		return 0, 0, errors.New("invalid size range " + s)
+	}
+	return min, max, nil
+}
+
+func (p *randomPayload) Next(string) ([]byte, error) {
+	b := make([]byte, p.min+rand.Intn(p.max-p.min+1))
+	rand.Read(b)
+	return b, nil
+}
+
+//TemplateData is the data to execute the payload templates
+type TemplateData struct {
+	Seq       int64
+	Timestamp int64 //unix time in milliseconds
+	Time      time.Time
+	ClientID  string
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var templateFuncs = template.FuncMap{
+	"randInt": func(min, max int) int {
+		return min + rand.Intn(max-min+1)
+	},
+	"randFloat": func(min, max float64) float64 {
+		return min + rand.Float64()*(max-min)
+	},
+	"randString": func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = letters[rand.Intn(len(letters))]
+		}
+		return string(b)
+	},
+	"randChoice": func(choices ...string) string {
+		return choices[rand.Intn(len(choices))]
+	},
+}
+
+type templatePayload struct {
+	t *template.Template
+}
+
+func (p *templatePayload) Next(clientID string) ([]byte, error) {
+	now := time.Now()
+	data := &TemplateData{
+		Seq:       atomic.AddInt64(&seq, 1) - 1,
+		Timestamp: now.UnixNano() / int64(time.Millisecond),
+		Time:      now,
+		ClientID:  clientID,
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := p.t.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//payloadFlags are the flags to choose a payload source, at most one of them can be set
+type payloadFlags struct {
+	file     string
+	stdin    bool
+	random   string
+	template string
+}
+
+func (p *payloadFlags) register(fs *flag.FlagSet, prefix string) {
+	fs.StringVar(&p.file, prefix+"file", "", "read the content of message from file")
+	fs.BoolVar(&p.stdin, prefix+"stdin", false, "read the content of message from stdin")
+	fs.StringVar(&p.random, prefix+"random", "", "random content of message with size, like 128 or a range like 64-256")
+	fs.StringVar(&p.template, prefix+"template", "", "go template of message, @file to read it from file. "+
+		"Fields: .Seq .Timestamp .Time .ClientID, funcs: randInt randFloat randString randChoice")
+}
+
+//payload returns nil if none of the flags is set
+func (p *payloadFlags) payload() (Payload, error) {
+	set := 0
+	for _, b := range []bool{p.file != "", p.stdin, p.random != "", p.template != ""} {
+		if b {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("only one of file, stdin, random and template can be set")
+	}
+
+	switch {
+	case p.file != "":
+		data, err := ioutil.ReadFile(p.file)
+		if err != nil {
+			return nil, err
+		}
+		return literalPayload(data), nil
+	case p.stdin:
+		data, err := readStdin()
+		if err != nil {
+			return nil, err
+		}
+		return literalPayload(data), nil
+	case p.random != "":
+		min, max, err := parseSizeRange(p.random)
+		if err != nil {
+			return nil, err
+		}
+		return &randomPayload{min: min, max: max}, nil
+	case p.template != "":
+		text := p.template
+		if strings.HasPrefix(text, "@") {
+			data, err := ioutil.ReadFile(text[1:])
+			if err != nil {
+				return nil, err
+			}
+			text = string(data)
+		}
+		t, err := template.New("payload").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		return &templatePayload{t: t}, nil
+	}
+	return nil, nil
+}
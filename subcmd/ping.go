@@ -7,11 +7,18 @@ import (
 	"github.com/shafreeck/mqttstat/mqtt"
 )
 
-func PingCommand(c *mqtt.Client, args []string) error {
-	var verbose bool
+type pingOptions struct {
+	verbose bool
+}
 
+func (o *pingOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.verbose, "v", false, "verbose")
+}
+
+func PingCommand(c *mqtt.Client, args []string) error {
+	var o pingOptions
 	fs := flag.NewFlagSet("ping", flag.ExitOnError)
-	fs.BoolVar(&verbose, "v", false, "verbose")
+	o.register(fs)
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -23,7 +30,7 @@ func PingCommand(c *mqtt.Client, args []string) error {
 	}
 
 	pong := <-ch
-	if verbose {
+	if o.verbose {
 		fmt.Println(pong)
 		fmt.Println()
 	}
@@ -0,0 +1,121 @@
+package subcmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+)
+
+const probeLen = 16
+
+//probe is the payload of the messages sent to measure the delivery latency
+type probe struct {
+	Publisher uint32
+	Seq       uint32
+	Sent      time.Time
+}
+
+//marshal encodes p and pads it to size bytes
+func (p *probe) marshal(size int) []byte {
+	if size < probeLen {
+		size = probeLen
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b, p.Publisher)
+	binary.BigEndian.PutUint32(b[4:], p.Seq)
+	binary.BigEndian.PutUint64(b[8:], uint64(p.Sent.UnixNano()))
+	return b
+}
+
+func unmarshalProbe(b []byte) (*probe, error) {
+	if len(b) < probeLen {
+		return nil, errors.New("message is too short to be a probe")
+	}
+	return &probe{
+		Publisher: binary.BigEndian.Uint32(b),
+		Seq:       binary.BigEndian.Uint32(b[4:]),
+		Sent:      time.Unix(0, int64(binary.BigEndian.Uint64(b[8:]))),
+	}, nil
+}
+
+//probeID identifies a probe by its publisher, the seqs of publishers overlap
+type probeID struct {
+	publisher, seq uint32
+}
+
+type delivery struct {
+	probeID
+	recipient int
+}
+
+//deliveries collects the probes received by all of the recipients
+type deliveries struct {
+	mu       sync.Mutex
+	latency  *bench.Histogram
+	seen     map[delivery]bool
+	sent     map[probeID]time.Time //the time each message was sent
+	last     map[probeID]time.Time //the time each message was received by the last recipient
+	end      time.Time            //the time the last probe was received
+	received int
+	dups     int
+	expected int
+	done     chan struct{}
+}
+
+func newDeliveries(expected int) *deliveries {
+	return &deliveries{latency: bench.NewHistogram(), seen: make(map[delivery]bool),
+		sent: make(map[probeID]time.Time), last: make(map[probeID]time.Time), expected: expected, done: make(chan struct{})}
+}
+
+func (d *deliveries) add(recipient int, message []byte) error {
+	now := time.Now()
+	p, err := unmarshalProbe(message)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m := probeID{publisher: p.Publisher, seq: p.Seq}
+	k := delivery{probeID: m, recipient: recipient}
+	if d.seen[k] {
+		d.dups++
+		return nil
+	}
+	d.seen[k] = true
+	d.received++
+	d.latency.Record(now.Sub(p.Sent))
+	if t, found := d.sent[m]; !found || p.Sent.Before(t) {
+		d.sent[m] = p.Sent
+	}
+	if now.After(d.last[m]) {
+		d.last[m] = now
+	}
+	d.end = now
+	if d.received == d.expected {
+		close(d.done)
+	}
+	return nil
+}
+
+//lastDelivery is the distribution of the time each message took to reach all of the recipients
+func (d *deliveries) lastDelivery() *bench.Histogram {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h := bench.NewHistogram()
+	for m, t := range d.last {
+		h.Record(t.Sub(d.sent[m]))
+	}
+	return h
+}
+
+//wait waits until all of the expected probes are received or timeout
+func (d *deliveries) wait(timeout time.Duration) {
+	select {
+	case <-d.done:
+	case <-time.After(timeout):
+	}
+}
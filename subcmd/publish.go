@@ -6,29 +6,49 @@ import (
 	"github.com/shafreeck/mqttstat/mqtt"
 )
 
-func PublishCommand(c *mqtt.Client, args []string) error {
-	var topic, message string
-	var qos int
-	var verbose bool
+type publishOptions struct {
+	topic, message string
+	qos            int
+	verbose        bool
+	pf             payloadFlags
+}
+
+func (o *publishOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.topic, "topic", "/mqttstat", "topic to publish message to")
+	fs.StringVar(&o.message, "message", "mqttstat test", "content of message")
+	fs.IntVar(&o.qos, "qos", 1, "qos of message")
+	fs.BoolVar(&o.verbose, "v", false, "verbose")
+	o.pf.register(fs, "")
+}
 
+func PublishCommand(c *mqtt.Client, args []string) error {
+	var o publishOptions
 	fs := flag.NewFlagSet("publish", flag.ExitOnError)
-	fs.StringVar(&topic, "topic", "/mqttstat", "topic to publish message to")
-	fs.StringVar(&message, "message", "mqttstat test", "content of message")
-	fs.IntVar(&qos, "qos", 1, "qos of message")
-	fs.BoolVar(&verbose, "v", false, "verbose")
+	o.register(fs)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	ackc, err := c.Publish(topic, []byte(message), qos)
+	var payload Payload = literalPayload(o.message)
+	if p, err := o.pf.payload(); err != nil {
+		return err
+	} else if p != nil {
+		payload = p
+	}
+	msg, err := payload.Next(c.ClientID())
+	if err != nil {
+		return err
+	}
+
+	ackc, err := c.Publish(o.topic, msg, o.qos)
 	if err != nil {
 		return err
 	}
 
 	ack := <-ackc
-	if verbose {
+	if o.verbose {
 		fmt.Println(ack.ControlPacket)
 		fmt.Println()
 	}
-	return nil
+	return ack.Err
 }
@@ -0,0 +1,138 @@
+package subcmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shafreeck/mqttstat/mqtt"
+	"github.com/shafreeck/mqttstat/report"
+)
+
+//Command is a subcommand of mqttstat, it either acts on a client connected
+//by mqttstat or manages its own clients
+type Command struct {
+	Name string
+	Help string
+	//Flags registers the options of the command for the usage, it may be nil
+	Flags func(fs *flag.FlagSet)
+
+	//Run acts on a connected client, it is nil if Clients is set
+	Run SubCommand
+	//Clients manages its own clients instead
+	Clients ClientsCommand
+
+	//Phases are measured after the client is connected, in order
+	Phases []report.Phase
+}
+
+var commands []*Command
+
+//Register adds cmd to the subcommands, it panics if the name is taken
+func Register(cmd *Command) {
+	if _, found := Lookup(cmd.Name); found {
+		panic("subcommand " + cmd.Name + " is registered twice")
+	}
+	if (cmd.Run == nil) == (cmd.Clients == nil) {
+		panic("subcommand " + cmd.Name + " should set one of Run and Clients")
+	}
+	commands = append(commands, cmd)
+}
+
+func Lookup(name string) (*Command, bool) {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+//Commands returns the subcommands in the order of registration
+func Commands() []*Command {
+	return commands[:len(commands):len(commands)]
+}
+
+//Usage writes the subcommands and their options
+func Usage(out io.Writer) {
+	fmt.Fprintln(out, "Subcommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-12v %v\n", cmd.Name, cmd.Help)
+		if cmd.Flags == nil {
+			continue
+		}
+		fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+		cmd.Flags(fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			name, usage := flag.UnquoteUsage(f)
+			line := "    -" + f.Name
+			if name != "" {
+				line += " " + name
+			}
+			fmt.Fprintf(out, "%-30v %v", line, strings.Replace(usage, "\n", " ", -1))
+			if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+				fmt.Fprintf(out, " (default %v)", f.DefValue)
+			}
+			fmt.Fprintln(out)
+		})
+	}
+}
+
+func init() {
+	Register(&Command{
+		Name:   "publish",
+		Help:   "publish a message and wait for the ack",
+		Flags:  func(fs *flag.FlagSet) { new(publishOptions).register(fs) },
+		Run:    PublishCommand,
+		Phases: []report.Phase{{Name: "MQTT Publish", Begin: mqtt.TracePublish, End: mqtt.TracePuback}},
+	})
+	Register(&Command{
+		Name:  "subscribe",
+		Help:  "subscribe topics, optionally publish to the first one and wait for the message",
+		Flags: func(fs *flag.FlagSet) { new(subscribeOptions).register(fs) },
+		Run:   SubscribeCommand,
+		Phases: []report.Phase{
+			{Name: "MQTT Subscribe", Begin: mqtt.TraceSubscribe, End: mqtt.TraceSuback},
+			{Name: "MQTT Publish", Begin: mqtt.TracePublish, End: mqtt.TracePuback},
+			{Name: "MQTT Message Received", End: mqtt.TraceMessage},
+		},
+	})
+	Register(&Command{
+		Name:   "ping",
+		Help:   "send PINGREQ and wait for PINGRESP",
+		Flags:  func(fs *flag.FlagSet) { new(pingOptions).register(fs) },
+		Run:    PingCommand,
+		Phases: []report.Phase{{Name: "MQTT PingPong", Begin: mqtt.TracePing, End: mqtt.TracePong}},
+	})
+	Register(&Command{
+		Name:    "storm",
+		Help:    "connect lots of clients at once, hold and reconnect them",
+		Flags:   func(fs *flag.FlagSet) { new(stormOptions).register(fs) },
+		Clients: StormCommand,
+	})
+	Register(&Command{
+		Name:    "fanout",
+		Help:    "publish to many subscribers and measure the deliveries",
+		Flags:   func(fs *flag.FlagSet) { new(fanFlags).register(fs, "subscribers") },
+		Clients: FanoutCommand,
+	})
+	Register(&Command{
+		Name:    "fanin",
+		Help:    "publish from many publishers to one subscriber and measure the deliveries",
+		Flags:   func(fs *flag.FlagSet) { new(fanFlags).register(fs, "publishers") },
+		Clients: FaninCommand,
+	})
+	Register(&Command{
+		Name:    "share",
+		Help:    "verify how the messages are distributed among a shared subscription",
+		Flags:   func(fs *flag.FlagSet) { new(shareOptions).register(fs) },
+		Clients: ShareCommand,
+	})
+	Register(&Command{
+		Name:    "tls-resume",
+		Help:    "compare full and resumed tls handshakes",
+		Flags:   func(fs *flag.FlagSet) { new(resumeOptions).register(fs) },
+		Clients: ResumeCommand,
+	})
+}
@@ -0,0 +1,147 @@
+package subcmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+//resumption names how a handshake was resumed. crypto/tls resumes TLS 1.3 by
+//PSK and TLS 1.2 by session tickets only, it never resumes by session ID
+func resumption(state *tls.ConnectionState) string {
+	switch {
+	case !state.DidResume:
+		return "full"
+	case state.Version == tls.VersionTLS13:
+		return "PSK"
+	default:
+		return "ticket"
+	}
+}
+
+//tlsCost is the time from starting the tls handshake to sending CONNECT, or
+//to starting the websocket upgrade of wss
+func tlsCost(points []*mqtt.TracePoint) time.Duration {
+	var begin, connect, upgrade time.Time
+	for _, p := range points {
+		switch p.Key {
+		case mqtt.TraceTLSDial:
+			begin = p.Time
+		case mqtt.TraceWebSocket:
+			upgrade = p.Time
+		case mqtt.TraceConnect:
+			connect = p.Time
+		}
+	}
+	if !upgrade.IsZero() {
+		return upgrade.Sub(begin)
+	}
+	return connect.Sub(begin)
+}
+
+type resumeOptions struct {
+	count          int
+	version        string
+	tickets        bool
+	delay, timeout time.Duration
+}
+
+func (o *resumeOptions) register(fs *flag.FlagSet) {
+	fs.IntVar(&o.count, "count", 5, "count of rounds, the first one is a full handshake")
+	fs.StringVar(&o.version, "version", "", "pin the tls version to 1.2 or 1.3, negotiated by default")
+	fs.BoolVar(&o.tickets, "tickets", true, "resume the sessions, disable to measure full handshakes only")
+	fs.DurationVar(&o.delay, "delay", 200*time.Millisecond, "time to delay before next round")
+	fs.DurationVar(&o.timeout, "timeout", 10*time.Second, "time to wait for connecting")
+}
+
+//ResumeCommand connects a number of rounds sharing a tls session cache and
+//compares the full handshakes with the resumed ones
+func ResumeCommand(cfg *mqtt.ClientConfig, address string, args []string) error {
+	var o resumeOptions
+	fs := flag.NewFlagSet("tls-resume", flag.ExitOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	scheme, _, _, err := mqtt.ParseURL(address)
+	if err != nil {
+		return err
+	}
+	if scheme != mqtt.TLSScheme && scheme != mqtt.WSSScheme {
+		return errors.New("tls-resume works only when connected by tls")
+	}
+	//the rounds share a config of their own, cfg is left as it is
+	tlsConfig := cfg.TLSConfig.Clone()
+	if o.version != "" {
+		v, found := tlsVersions[o.version]
+		if !found {
+			return errors.New("unknown tls version " + o.version + ", should be 1.2 or 1.3")
+		}
+		tlsConfig.MinVersion = v
+		tlsConfig.MaxVersion = v
+	}
+	tlsConfig.SessionTicketsDisabled = !o.tickets
+	if o.tickets && tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	full := bench.NewHistogram()
+	resumed := bench.NewHistogram()
+
+	fmt.Println("TLS resumption of", address, "in", o.count, "rounds")
+	fmt.Println()
+	fmt.Printf("%-6v  %-8v  %-8v  %-10v  %-40v  %12v\n", "Round", "Version", "Resumed", "Mechanism", "Cipher suite", "TLS cost")
+	for i := 0; i < o.count; i++ {
+		if i > 0 {
+			time.Sleep(o.delay)
+		}
+		c := mqtt.NewClient(cfg)
+		c.SetClientID(cfg.ClientID + "-" + strconv.Itoa(i))
+		c.SetRound(i + 1)
+		c.SetTLSConfig(tlsConfig)
+		if err := c.Dial(address, net.Dialer{Timeout: o.timeout}); err != nil {
+			return &clientError{ClientID: c.ClientID(), Err: err}
+		}
+		c.Disconnect()
+
+		state := c.TLSState()
+		cost := tlsCost(c.TracePoints())
+		if state.DidResume {
+			resumed.Record(cost)
+		} else {
+			full.Record(cost)
+		}
+		fmt.Printf("%-6v  %-8v  %-8v  %-10v  %-40v  %12v\n", i+1, tls.VersionName(state.Version), state.DidResume,
+			resumption(state), tls.CipherSuiteName(state.CipherSuite), cost)
+	}
+	fmt.Println()
+
+	bench.PrintHeader(os.Stdout)
+	bench.PrintRow(os.Stdout, "Full handshake", full)
+	bench.PrintRow(os.Stdout, "Resumed handshake", resumed)
+	fmt.Println()
+
+	switch {
+	case !o.tickets:
+	case resumed.Count() == 0:
+		fmt.Println("No handshake was resumed, the server may not issue session tickets or may resume by session IDs only")
+	case full.Count() > 0:
+		fmt.Printf("%-12v: %.2fx of the median\n", "Speedup", float64(full.Percentile(50))/float64(resumed.Percentile(50)))
+	}
+	fmt.Printf("%-12v: %v\n", "Not measured", "0-RTT early data and session ID resumption, crypto/tls supports neither")
+	return nil
+}
@@ -0,0 +1,183 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+//shareMembers collects the messages received by the members of a share group
+type shareMembers struct {
+	mu       sync.Mutex
+	latency  *bench.Histogram
+	received []int
+	seen     map[uint32]int //times each seq was received by any of the members
+}
+
+func (m *shareMembers) add(member int, message []byte) error {
+	now := time.Now()
+	p, err := unmarshalProbe(message)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received[member]++
+	m.seen[p.Seq]++
+	if m.seen[p.Seq] == 1 {
+		m.latency.Record(now.Sub(p.Sent))
+	}
+	return nil
+}
+
+//fairness is the Jain's fairness index of the counts, 1 means evenly distributed
+func fairness(counts []int) float64 {
+	var sum, squares float64
+	for _, n := range counts {
+		sum += float64(n)
+		squares += float64(n) * float64(n)
+	}
+	if squares == 0 {
+		return 0
+	}
+	return sum * sum / (float64(len(counts)) * squares)
+}
+
+type shareOptions struct {
+	topic, group                      string
+	clients, count, qos, drop, dropAt int
+	interval, timeout                 time.Duration
+}
+
+func (o *shareOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.topic, "topic", "/mqttstat", "topic to publish message to")
+	fs.StringVar(&o.group, "group", "mqttstat", "name of the share group")
+	fs.IntVar(&o.clients, "clients", 10, "count of members in the share group")
+	fs.IntVar(&o.count, "count", 1000, "count of messages to publish")
+	fs.IntVar(&o.qos, "qos", 1, "qos of message")
+	fs.IntVar(&o.drop, "drop", 0, "count of members to disconnect in the middle of the run")
+	fs.IntVar(&o.dropAt, "dropat", -1, "disconnect the members before publishing this seq, half of count by default")
+	fs.DurationVar(&o.interval, "interval", time.Millisecond, "time to delay before publishing the next message")
+	fs.DurationVar(&o.timeout, "timeout", 10*time.Second, "time to wait for the messages and for connecting")
+}
+
+//ShareCommand verifies how the messages are distributed among the members of
+//a shared subscription
+func ShareCommand(cfg *mqtt.ClientConfig, address string, args []string) error {
+	var o shareOptions
+	fs := flag.NewFlagSet("share", flag.ExitOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if o.drop >= o.clients {
+		return errors.New("drop should be less than clients")
+	}
+	if o.dropAt < 0 {
+		o.dropAt = o.count / 2
+	}
+	if o.drop > 0 && o.dropAt >= o.count {
+		return errors.New("dropat should be less than count")
+	}
+
+	m := &shareMembers{latency: bench.NewHistogram(), received: make([]int, o.clients), seen: make(map[uint32]int)}
+	subs, err := dialClients(cfg, address, "sub", o.clients, o.timeout, func(i int, c *mqtt.Client) {
+		c.SetRecvHandler(func(topic string, message []byte, qos int) error {
+			return m.add(i, message)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	//the dropped members are left out of subs once they are closed
+	defer func() { disconnectClients(subs) }()
+
+	filter := "$share/" + o.group + "/" + o.topic
+	if err := subscribeClients(subs, func(int) string { return filter }, o.qos); err != nil {
+		return err
+	}
+
+	pubs, err := dialClients(cfg, address, "pub", 1, o.timeout, nil)
+	if err != nil {
+		return err
+	}
+	defer disconnectClients(pubs)
+
+	fmt.Println("Shared subscription", filter, "with", o.clients, "members,", o.count, "messages")
+	fmt.Println()
+
+	dropped := subs[o.clients-o.drop:]
+	for seq := 0; seq < o.count; seq++ {
+		if seq == o.dropAt && o.drop > 0 {
+			for _, c := range dropped {
+				c.Close()
+			}
+			subs = subs[:o.clients-o.drop]
+		}
+
+		p := &probe{Seq: uint32(seq), Sent: time.Now()}
+		ackc, err := pubs[0].Publish(o.topic, p.marshal(probeLen), o.qos)
+		if err != nil {
+			return err
+		}
+		if err := waitAck(ackc, o.timeout); err != nil {
+			return err
+		}
+		time.Sleep(o.interval)
+	}
+
+	//wait until no more messages arrive
+	deadline := time.Now().Add(o.timeout)
+	for last := -1; time.Now().Before(deadline); {
+		m.mu.Lock()
+		n := len(m.seen)
+		m.mu.Unlock()
+		if n == o.count || n == last {
+			break
+		}
+		last = n
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var dups int
+	for _, n := range m.seen {
+		dups += n - 1
+	}
+	fmt.Printf("%-12v: %v\n", "Delivered", len(m.seen))
+	fmt.Printf("%-12v: %v\n", "Missing", o.count-len(m.seen))
+	fmt.Printf("%-12v: %v\n", "Duplicated", dups)
+	fmt.Printf("%-12v: %.4f\n", "Fairness", fairness(m.received[:o.clients-o.drop]))
+	fmt.Println()
+
+	fmt.Printf("%-21v  %8v  %8v\n", "Member", "received", "share")
+	expected := float64(o.count) / float64(o.clients)
+	var deviation float64
+	for i, n := range m.received {
+		note := ""
+		if i >= o.clients-o.drop {
+			note = fmt.Sprintf("  (dropped before seq %v)", o.dropAt)
+		}
+		fmt.Printf("%-21v  %8v  %7.2f%%%v\n", cfg.ClientID+"-sub-"+fmt.Sprint(i), n, float64(n)*100/float64(o.count), note)
+		deviation += (float64(n) - expected) * (float64(n) - expected)
+	}
+	fmt.Printf("%-21v  %8.2f\n", "stddev", math.Sqrt(deviation/float64(o.clients)))
+	fmt.Println()
+
+	if m.latency.Count() > 0 {
+		bench.PrintHeader(os.Stdout)
+		bench.PrintRow(os.Stdout, "Delivery latency", m.latency)
+	}
+	return nil
+}
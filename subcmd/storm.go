@@ -0,0 +1,214 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shafreeck/mqttstat/bench"
+	"github.com/shafreeck/mqttstat/mqtt"
+)
+
+const (
+	errRefused       = "refused"
+	errFDExhausted   = "fd exhausted"
+	errPortExhausted = "port exhausted"
+	errTimeout       = "timeout"
+	errOther         = "other"
+)
+
+var errClasses = []string{errRefused, errFDExhausted, errPortExhausted, errTimeout, errOther}
+
+//classify tells the reason of a failed connection
+func classify(err error) string {
+	var ce *mqtt.ConnectError
+	var ne net.Error
+	switch {
+	case errors.As(err, &ce), errors.Is(err, syscall.ECONNREFUSED):
+		return errRefused
+	case errors.Is(err, syscall.EMFILE), errors.Is(err, syscall.ENFILE):
+		return errFDExhausted
+	case errors.Is(err, syscall.EADDRNOTAVAIL), errors.Is(err, mqtt.ErrNoFreePort):
+		return errPortExhausted
+	case errors.As(err, &ne) && ne.Timeout():
+		return errTimeout
+	}
+	return errOther
+}
+
+//stormPhase collects the results of connecting all of the clients once
+type stormPhase struct {
+	name     string
+	interval time.Duration
+	begin    time.Time
+	elapsed  time.Duration
+
+	connack []time.Duration //from CONNECT to CONNACK of every seq
+	latency *bench.Histogram
+	buckets map[int]*bench.Histogram //connack latency by the interval the connection finished in
+	errs    map[string]int
+	samples map[string]error //the first error of each class
+}
+
+func newStormPhase(name string, interval time.Duration, clients int) *stormPhase {
+	return &stormPhase{name: name, interval: interval,
+		connack: make([]time.Duration, clients),
+		latency: bench.NewHistogram(),
+		buckets: make(map[int]*bench.Histogram),
+		errs:    make(map[string]int),
+		samples: make(map[string]error)}
+}
+
+func (p *stormPhase) report(r *bench.Result) {
+	if r.Err != nil {
+		class := classify(r.Err)
+		p.errs[class]++
+		if _, found := p.samples[class]; !found {
+			p.samples[class] = r.Err
+		}
+		return
+	}
+
+	p.latency.Record(p.connack[r.Seq])
+	i := int(r.End.Sub(p.begin) / p.interval)
+	h, found := p.buckets[i]
+	if !found {
+		h = bench.NewHistogram()
+		p.buckets[i] = h
+	}
+	h.Record(p.connack[r.Seq])
+}
+
+//connackLatency is the time from sending CONNECT to receiving CONNACK
+func connackLatency(points []*mqtt.TracePoint) time.Duration {
+	var connect, connack time.Time
+	for _, p := range points {
+		switch p.Key {
+		case mqtt.TraceConnect:
+			connect = p.Time
+		case mqtt.TraceConnack:
+			connack = p.Time
+		}
+	}
+	return connack.Sub(connect)
+}
+
+func (p *stormPhase) display() {
+	fmt.Println(p.name)
+	fmt.Printf("  %-16v: %v\n", "Connected", p.latency.Count())
+	failed := 0
+	for _, n := range p.errs {
+		failed += n
+	}
+	fmt.Printf("  %-16v: %v\n", "Failed", failed)
+	for _, class := range errClasses {
+		if n := p.errs[class]; n > 0 {
+			fmt.Printf("    %-14v: %v (%v)\n", class, n, p.samples[class])
+		}
+	}
+	fmt.Printf("  %-16v: %v\n", "Elapsed", p.elapsed)
+	fmt.Println()
+
+	if p.latency.Count() == 0 {
+		return
+	}
+
+	var is []int
+	for i := range p.buckets {
+		is = append(is, i)
+	}
+	sort.Ints(is)
+
+	bench.PrintHeader(os.Stdout)
+	bench.PrintRow(os.Stdout, "CONNACK latency", p.latency)
+	for _, i := range is {
+		bench.PrintRow(os.Stdout, "  +"+(time.Duration(i+1)*p.interval).String(), p.buckets[i])
+	}
+	fmt.Println()
+}
+
+type stormOptions struct {
+	clients, reconnect      int
+	ramp                    float64
+	hold, interval, timeout time.Duration
+	abort                   bool
+}
+
+func (o *stormOptions) register(fs *flag.FlagSet) {
+	fs.IntVar(&o.clients, "clients", 1000, "count of clients to connect")
+	fs.Float64Var(&o.ramp, "ramp", 0, "connections to open per second, 0 means as fast as possible")
+	fs.DurationVar(&o.hold, "hold", 5*time.Second, "time to hold the connections")
+	fs.IntVar(&o.reconnect, "reconnect", 0, "times to drop and reconnect all of the clients")
+	fs.BoolVar(&o.abort, "abort", true, "drop connections without sending DISCONNECT")
+	fs.DurationVar(&o.interval, "interval", time.Second, "interval to show the connack latency over time")
+	fs.DurationVar(&o.timeout, "timeout", 10*time.Second, "timeout of tcp connection")
+}
+
+//StormCommand connects lots of clients at once, holds them and optionally
+//drops and reconnects all of them, like devices do after a broker restart
+func StormCommand(cfg *mqtt.ClientConfig, address string, args []string) error {
+	var o stormOptions
+	fs := flag.NewFlagSet("storm", flag.ExitOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if o.interval <= 0 {
+		return errors.New("interval should be positive")
+	}
+
+	fmt.Println("Storm of", o.clients, "clients to", address)
+	fmt.Println()
+
+	cs := make([]*mqtt.Client, o.clients)
+	for round := 0; round <= o.reconnect; round++ {
+		name := "Connect"
+		if round > 0 {
+			name = "Reconnect #" + strconv.Itoa(round)
+		}
+		phase := newStormPhase(name, o.interval, o.clients)
+
+		s := bench.NewScheduler(o.ramp, bench.Constant)
+		phase.begin = time.Now()
+		s.Run(o.clients, func(seq int) error {
+			c := mqtt.NewClient(cfg)
+			c.SetClientID(cfg.ClientID + "-" + strconv.Itoa(seq))
+			if err := c.Dial(address, net.Dialer{Timeout: o.timeout}); err != nil {
+				return err
+			}
+			phase.connack[seq] = connackLatency(c.TracePoints())
+			cs[seq] = c
+			return nil
+		}, phase.report, nil)
+		phase.elapsed = time.Since(phase.begin)
+		phase.display()
+
+		time.Sleep(o.hold)
+
+		var wg sync.WaitGroup
+		for i, c := range cs {
+			if c == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(c *mqtt.Client) {
+				defer wg.Done()
+				if o.abort && round < o.reconnect {
+					c.Close()
+				} else {
+					c.Disconnect()
+				}
+			}(c)
+			cs[i] = nil
+		}
+		wg.Wait()
+	}
+	return nil
+}
@@ -5,3 +5,6 @@ import (
 )
 
 type SubCommand func(c *mqtt.Client, args []string) error
+
+//ClientsCommand manages its own clients instead of acting on a connected one
+type ClientsCommand func(cfg *mqtt.ClientConfig, address string, args []string) error
@@ -11,23 +11,47 @@ import (
 	"github.com/shafreeck/mqttstat/mqtt"
 )
 
-func SubscribeCommand(c *mqtt.Client, args []string) error {
-	var topic, pub string
-	var qos string
-	var wait, verbose bool
+type subscribeOptions struct {
+	topic, pub    string
+	qos           string
+	wait, verbose bool
+	pf            payloadFlags
+}
+
+func (o *subscribeOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.topic, "topic", "/mqttstat", "topic to publish message to")
+	fs.StringVar(&o.pub, "pub", "", "publish message to the first topic, the content should be encoded by base64")
+	fs.StringVar(&o.qos, "qos", "1", "qos of message")
+	fs.BoolVar(&o.wait, "wait", false, "wait for the first message")
+	fs.BoolVar(&o.verbose, "v", false, "verbose")
+	o.pf.register(fs, "pub.")
+}
 
+func SubscribeCommand(c *mqtt.Client, args []string) error {
+	var o subscribeOptions
 	fs := flag.NewFlagSet("subscribe", flag.ExitOnError)
-	fs.StringVar(&topic, "topic", "/mqttstat", "topic to publish message to")
-	fs.StringVar(&pub, "pub", "", "publish message to the first topic, the content should be encoded by base64")
-	fs.StringVar(&qos, "qos", "1", "qos of message")
-	fs.BoolVar(&wait, "wait", false, "wait for the first message")
-	fs.BoolVar(&verbose, "v", false, "verbose")
+	o.register(fs)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	topics := strings.Split(topic, ",")
-	rawqoss := strings.Split(qos, ",")
+	payload, err := o.pf.payload()
+	if err != nil {
+		return err
+	}
+	if o.pub != "" {
+		if payload != nil {
+			return errors.New("pub can not be used with the other pub options")
+		}
+		msg, err := base64.StdEncoding.DecodeString(o.pub)
+		if err != nil {
+			return err
+		}
+		payload = literalPayload(msg)
+	}
+
+	topics := strings.Split(o.topic, ",")
+	rawqoss := strings.Split(o.qos, ",")
 	if len(topics) != len(rawqoss) {
 		return errors.New("size of topics and qoss does not match")
 	}
@@ -43,9 +67,9 @@ func SubscribeCommand(c *mqtt.Client, args []string) error {
 
 	waitc := make(chan struct{}, 1)
 	//wait for the first message
-	if wait {
+	if o.wait {
 		c.SetRecvHandler(func(topic string, message []byte, qos int) error {
-			if verbose {
+			if o.verbose {
 				log.Printf("topic: %s, message size: %d, qos: %d", topic, len(message), qos)
 			}
 			waitc <- struct{}{}
@@ -58,20 +82,22 @@ func SubscribeCommand(c *mqtt.Client, args []string) error {
 	}
 
 	//publish messge before subscription, sometimes we want to upload some init message first
-	if pub != "" {
-		msg, err := base64.StdEncoding.DecodeString(pub)
+	if payload != nil {
+		msg, err := payload.Next(c.ClientID())
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
 
 		ackc, err := c.Publish(topics[0], msg, 1)
 		if err != nil {
 			return err
 		}
-		<-ackc
+		if ack := <-ackc; ack.Err != nil {
+			return ack.Err
+		}
 	}
 
-	if wait {
+	if o.wait {
 		<-waitc
 	}
 	return nil